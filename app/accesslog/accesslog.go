@@ -0,0 +1,175 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"v2ray.com/core/app/tracker"
+	"v2ray.com/core/common/log"
+)
+
+// Format is the line format of the access log.
+type Format byte
+
+const (
+	Text Format = iota
+	JSON
+)
+
+const (
+	timeFormat = "2006/01/02 15:04:05"
+)
+
+type Setting struct {
+	Format Format
+	// Path is the file appended to, the stdout is written if empty.
+	Path     string
+	Rotation log.Rotation
+}
+
+// Logger writes a line for every ended session.
+type Logger interface {
+	Log(tracker.Connection)
+	Reopen() error
+	Close() error
+}
+
+type logger struct {
+	format Format
+
+	sync.Mutex
+	writer io.WriteCloser
+}
+
+func NewLogger(setting Setting) (Logger, error) {
+	writer, err := open(setting.Path, setting.Rotation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logger{
+		format: setting.Format,
+		writer: writer,
+	}, nil
+}
+
+func open(path string, rotation log.Rotation) (io.WriteCloser, error) {
+	if len(path) == 0 {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	file, err := log.NewFileWriter(path, rotation)
+	if err != nil {
+		return nil, newError("failed to open access log %s", path).WithError(err)
+	}
+	return file, nil
+}
+
+func (l *logger) Log(c tracker.Connection) {
+	var line []byte
+
+	switch l.format {
+	case JSON:
+		b, err := json.Marshal(newEntry(c))
+		if err != nil {
+			newError("failed to marshal access log").WithError(err).AtDebug().Logging()
+			return
+		}
+		line = append(b, '\n')
+	default:
+		line = []byte(textLine(c))
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	if _, err := l.writer.Write(line); err != nil {
+		newError("failed to write access log").WithError(err).AtDebug().Logging()
+	}
+}
+
+func (l *logger) Reopen() error {
+	l.Lock()
+	defer l.Unlock()
+
+	if reopener, ok := l.writer.(log.Reopener); ok {
+		return reopener.Reopen()
+	}
+	return nil
+}
+
+func (l *logger) Close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.writer.Close()
+}
+
+type entry struct {
+	Time        time.Time `json:"time"`
+	Session     uint32    `json:"session"`
+	Source      string    `json:"source"`
+	Destination string    `json:"destination"`
+	Sniffed     string    `json:"sniffed,omitempty"`
+	InboundTag  string    `json:"inboundTag"`
+	OutboundTag string    `json:"outboundTag,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	Result      string    `json:"result"`
+	Duration    float64   `json:"duration"`
+	Uplink      int64     `json:"uplink"`
+	Downlink    int64     `json:"downlink"`
+}
+
+func newEntry(c tracker.Connection) entry {
+	return entry{
+		Time:        c.Start,
+		Session:     uint32(c.ID),
+		Source:      c.Source.NetworkAndDomainPreferredAddress(),
+		Destination: c.Destination.NetworkAndDomainPreferredAddress(),
+		Sniffed:     c.Sniffed,
+		InboundTag:  c.InboundTag,
+		OutboundTag: c.OutboundTag,
+		Email:       c.Email,
+		Result:      c.Result.String(),
+		Duration:    c.End.Sub(c.Start).Seconds(),
+		Uplink:      c.Uplink,
+		Downlink:    c.Downlink,
+	}
+}
+
+// textLine is like
+// 2006/01/02 15:04:05 tcp:127.0.0.1:50000 accepted tcp:1.2.3.4:443 [example.com] [socks >> direct] [alice] 1.5s 100 2000
+func textLine(c tracker.Connection) string {
+	outboundTag := c.OutboundTag
+	if len(outboundTag) == 0 {
+		outboundTag = "-"
+	}
+
+	return fmt.Sprintf("%s %s %s %s [%s] [%s >> %s] [%s] %s %d %d\n",
+		c.Start.Format(timeFormat),
+		c.Source.NetworkAndDomainPreferredAddress(),
+		c.Result,
+		c.Destination.NetworkAndDomainPreferredAddress(),
+		c.Sniffed,
+		c.InboundTag,
+		outboundTag,
+		c.Email,
+		c.End.Sub(c.Start).Round(time.Millisecond),
+		c.Uplink,
+		c.Downlink,
+	)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"v2ray.com/core/app/proxyman/inbound"
+	"v2ray.com/core/app/proxyman/outbound"
+	"v2ray.com/core/app/router"
+	"v2ray.com/core/app/stats"
+	"v2ray.com/core/app/tracker"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
+)
+
+const (
+	unixPrefix = "unix:"
+
+	authorizationPrefix = "Bearer "
+)
+
+// AddFunc loads the handlers of the json body.
+type AddFunc = func([]byte) error
+
+// BuildUserFunc builds the user of the json body.
+type BuildUserFunc = func([]byte) (protocol.RequestUser, error)
+
+// ReloadFunc reads the conf again and applies the changed handlers.
+type ReloadFunc = func() error
+
+type Setting struct {
+	// Listen is a local address like 127.0.0.1:10085, or a unix socket like unix:/run/v2ray.sock.
+	Listen string
+	Token  string
+
+	InboundManager  inbound.Manager
+	OutboundManager outbound.Manager
+	OutboundMatcher router.Matcher
+	Stats           stats.Manager
+	Tracker         tracker.Tracker
+
+	AddInboundFunc  AddFunc
+	AddOutboundFunc AddFunc
+	BuildUserFunc   BuildUserFunc
+	ReloadFunc      ReloadFunc
+	// Locker is held while the handlers or the users are changed, so that they are never changed during a reload.
+	Locker sync.Locker
+}
+
+type Server interface {
+	Close() error
+}
+
+type server struct {
+	setting  Setting
+	listener net.Listener
+	server   *http.Server
+}
+
+func NewServer(setting Setting) (Server, error) {
+	if len(setting.Token) == 0 {
+		return nil, newError("api token is required")
+	}
+
+	listener, err := listen(setting.Listen)
+	if err != nil {
+		return nil, newError("failed to listen api on %s", setting.Listen).WithError(err)
+	}
+
+	s := &server{
+		setting:  setting,
+		listener: listener,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/inbounds", s.handleInbounds)
+	mux.HandleFunc("/inbounds/", s.handleInbound)
+	mux.HandleFunc("/outbounds", s.handleOutbounds)
+	mux.HandleFunc("/outbounds/", s.handleOutbound)
+	mux.HandleFunc("/stats", s.handleStats)
+	mux.HandleFunc("/connections", s.handleConnections)
+	mux.HandleFunc("/connections/", s.handleConnection)
+	mux.HandleFunc("/route", s.handleRoute)
+	mux.HandleFunc("/log", s.handleLog)
+	mux.HandleFunc("/reload", s.handleReload)
+
+	s.server = &http.Server{
+		Handler: s.authorize(mux),
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			newError("failed to serve api").WithError(err).AtWarning().Logging()
+		}
+	}()
+
+	newError("api listening on %s", setting.Listen).AtInfo().Logging()
+
+	return s, nil
+}
+
+func (s *server) Close() error {
+	return s.server.Close()
+}
+
+// lock holds the Locker and returns the unlocking, it does nothing without the Locker.
+func (s *server) lock() func() {
+	if s.setting.Locker == nil {
+		return func() {}
+	}
+
+	s.setting.Locker.Lock()
+	return s.setting.Locker.Unlock
+}
+
+// listen listens on a loopback address or a unix socket, a stale socket file is removed.
+func listen(address string) (net.Listener, error) {
+	if path := strings.TrimPrefix(address, unixPrefix); path != address {
+		if info, err := os.Stat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				_ = conn.Close()
+				return nil, newError("api socket %s is in use", path)
+			}
+			if err := os.Remove(path); err != nil {
+				return nil, newError("failed to remove stale api socket %s", path).WithError(err)
+			}
+		}
+		return net.Listen("unix", path)
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, newError("api must listen on a loopback address, not [%s]", host)
+	}
+
+	return net.Listen("tcp", address)
+}
+
+func (s *server) authorize(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization := r.Header.Get("Authorization")
+		token := strings.TrimPrefix(authorization, authorizationPrefix)
+		if len(token) == len(authorization) || subtle.ConstantTimeCompare([]byte(token), []byte(s.setting.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, newError("invalid token"))
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(v); err != nil {
+		newError("failed to write api response").WithError(err).AtDebug().Logging()
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, map[string]string{
+		"error": err.Error(),
+	})
+}
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
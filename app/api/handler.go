@@ -0,0 +1,349 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/app/proxyman/inbound"
+	"v2ray.com/core/common/log"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+)
+
+const (
+	// maxBodySize is the largest json body accepted.
+	maxBodySize = 1024 * 1024
+)
+
+// handleInbounds lists the inbound tags on GET and loads the inbounds of the body on POST.
+func (s *server) handleInbounds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var tags []string
+		s.setting.InboundManager.Range(func(handler proxyman.Inbound) bool {
+			tags = append(tags, handler.Tag())
+			return true
+		})
+
+		writeJSON(w, http.StatusOK, map[string][]string{
+			"inbounds": uniqueTags(tags),
+		})
+	case http.MethodPost:
+		s.add(w, r, s.setting.AddInboundFunc)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+	}
+}
+
+// handleInbound serves DELETE /inbounds/{tag}, POST /inbounds/{tag}/users and DELETE /inbounds/{tag}/users/{email}.
+func (s *server) handleInbound(w http.ResponseWriter, r *http.Request) {
+	defer s.lock()()
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/inbounds/"), "/")
+
+	handlers := s.inboundHandlers(parts[0])
+	if len(handlers) == 0 {
+		writeError(w, http.StatusNotFound, newError("inbound handler not found [%s]", parts[0]))
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == http.MethodDelete:
+		s.removeInbound(w, parts[0], handlers)
+	case len(parts) == 2 && parts[1] == "users" && r.Method == http.MethodPost:
+		s.addUser(w, r, parts[0], handlers)
+	case len(parts) == 3 && parts[1] == "users" && r.Method == http.MethodDelete:
+		s.removeUser(w, parts[0], parts[2], handlers)
+	default:
+		writeError(w, http.StatusNotFound, newError("unsupported %s %s", r.Method, r.URL.Path))
+	}
+}
+
+// inboundHandlers returns the tcp and udp handlers of the inbound.
+func (s *server) inboundHandlers(tag string) []proxyman.Inbound {
+	var handlers []proxyman.Inbound
+	s.setting.InboundManager.Range(func(handler proxyman.Inbound) bool {
+		if handler.Tag() == tag {
+			handlers = append(handlers, handler)
+		}
+		return true
+	})
+	return handlers
+}
+
+func (s *server) removeInbound(w http.ResponseWriter, tag string, handlers []proxyman.Inbound) {
+	for _, handler := range handlers {
+		s.setting.InboundManager.Delete(inbound.Key{
+			Tag:     handler.Tag(),
+			Network: handler.Network(),
+		})
+
+		if err := handler.Close(); err != nil {
+			newError("failed to close inbound handler [%s]", tag).WithError(err).AtDebug().Logging()
+		}
+	}
+
+	newError("removed inbound handler [%s]", tag).AtInfo().Logging()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// addUser adds the user of the body to every handler of the inbound, it is removed again if any fails.
+func (s *server) addUser(w http.ResponseWriter, r *http.Request, tag string, handlers []proxyman.Inbound) {
+	if s.setting.BuildUserFunc == nil {
+		writeError(w, http.StatusNotImplemented, newError("adding users is unsupported"))
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, newError("failed to read body").WithError(err))
+		return
+	}
+
+	user, err := s.setting.BuildUserFunc(b)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	for i, handler := range handlers {
+		if err := handler.AddUser(user); err != nil {
+			for _, added := range handlers[:i] {
+				_ = added.RemoveUser(user.Email)
+			}
+
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	newError("added user [%s] to inbound handler [%s]", user.Email, tag).AtInfo().Logging()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) removeUser(w http.ResponseWriter, tag, email string, handlers []proxyman.Inbound) {
+	var errs []error
+	for _, handler := range handlers {
+		if err := handler.RemoveUser(email); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(handlers) {
+		writeError(w, http.StatusNotFound, errs[0])
+		return
+	}
+
+	newError("removed user [%s] from inbound handler [%s]", email, tag).AtInfo().Logging()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleOutbounds lists the outbound tags on GET and loads the outbounds of the body on POST.
+func (s *server) handleOutbounds(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		var tags []string
+		s.setting.OutboundManager.Range(func(handler proxyman.Outbound) bool {
+			tags = append(tags, handler.Tag())
+			return true
+		})
+
+		writeJSON(w, http.StatusOK, map[string][]string{
+			"outbounds": uniqueTags(tags),
+		})
+	case http.MethodPost:
+		s.add(w, r, s.setting.AddOutboundFunc)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+	}
+}
+
+// handleOutbound removes the outbound on DELETE, the links it is handling are kept.
+func (s *server) handleOutbound(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	defer s.lock()()
+
+	tag := strings.TrimPrefix(r.URL.Path, "/outbounds/")
+
+	if _, ok := s.setting.OutboundManager.Get(tag); !ok {
+		writeError(w, http.StatusNotFound, newError("outbound handler not found [%s]", tag))
+		return
+	}
+
+	s.setting.OutboundManager.Delete(tag)
+
+	newError("removed outbound handler [%s]", tag).AtInfo().Logging()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *server) add(w http.ResponseWriter, r *http.Request, addFunc AddFunc) {
+	if addFunc == nil {
+		writeError(w, http.StatusNotImplemented, newError("adding handlers is unsupported"))
+		return
+	}
+
+	b, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, newError("failed to read body").WithError(err))
+		return
+	}
+
+	defer s.lock()()
+
+	if err := addFunc(b); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStats returns the counters with the prefix on GET, they are reset with reset=true.
+func (s *server) handleStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	query := r.URL.Query()
+
+	reset, err := parseBool(query.Get("reset"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]map[string]int64{
+		"stats": s.setting.Stats.Snapshot(query.Get("prefix"), reset),
+	})
+}
+
+// handleRoute returns the outbound tag a synthetic connection is routed to on GET,
+// like /route?network=tcp&address=example.com:443&inboundTag=socks&source=127.0.0.1:1234.
+func (s *server) handleRoute(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	query := r.URL.Query()
+
+	network := query.Get("network")
+	if len(network) == 0 {
+		network = net.Network_TCP
+	}
+
+	address, err := net.ParseAddress(network, query.Get("address"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, newError("invalid address %s", query.Get("address")).WithError(err))
+		return
+	}
+
+	ib := session.Inbound{
+		Tag: query.Get("inboundTag"),
+	}
+	if source := query.Get("source"); len(source) > 0 {
+		ib.Source, err = net.ParseAddress(network, source)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, newError("invalid source %s", source).WithError(err))
+			return
+		}
+	}
+
+	content := session.NewContent()
+	defer func() {
+		_ = content.Close()
+	}()
+	content.SetInbound(ib)
+
+	tag, ok := s.setting.OutboundMatcher.MatchContent(content, address)
+	if !ok {
+		writeError(w, http.StatusNotFound, newError("no matched outbound for [%s]", address.NetworkAndDomainPreferredAddress()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"outboundTag": tag,
+	})
+}
+
+// handleLog returns the log level on GET and changes it by level=debug/info/warning/error/none on PUT.
+func (s *server) handleLog(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		level, ok := log.ParseLevel(r.URL.Query().Get("level"))
+		if !ok {
+			writeError(w, http.StatusBadRequest, newError("unknown log level %s", r.URL.Query().Get("level")))
+			return
+		}
+
+		log.SetLevel(level)
+
+		newError("log level changed to %s", level).AtInfo().Logging()
+	default:
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"level": log.GetLevel().String(),
+	})
+}
+
+// handleReload reads the conf again on POST, the inbounds and outbounds changed are replaced and the rules are swapped.
+func (s *server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	if s.setting.ReloadFunc == nil {
+		writeError(w, http.StatusNotImplemented, newError("reloading is unsupported"))
+		return
+	}
+
+	if err := s.setting.ReloadFunc(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func uniqueTags(tags []string) []string {
+	sort.Strings(tags)
+
+	unique := make([]string, 0, len(tags))
+	for i, tag := range tags {
+		if i > 0 && tag == tags[i-1] {
+			continue
+		}
+		unique = append(unique, tag)
+	}
+	return unique
+}
+
+func parseBool(s string) (bool, error) {
+	if len(s) == 0 {
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return false, newError("invalid bool %s", s).WithError(err)
+	}
+	return b, nil
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"v2ray.com/core/app/tracker"
+	"v2ray.com/core/common/session"
+)
+
+type connection struct {
+	ID          session.ID `json:"id"`
+	InboundTag  string     `json:"inboundTag"`
+	Source      string     `json:"source"`
+	Email       string     `json:"email,omitempty"`
+	Destination string     `json:"destination"`
+	Sniffed     string     `json:"sniffed,omitempty"`
+	OutboundTag string     `json:"outboundTag,omitempty"`
+	Result      string     `json:"result"`
+	Start       time.Time  `json:"start"`
+	Uplink      int64      `json:"uplink"`
+	Downlink    int64      `json:"downlink"`
+}
+
+func newConnection(c tracker.Connection) connection {
+	return connection{
+		ID:          c.ID,
+		InboundTag:  c.InboundTag,
+		Source:      c.Source.NetworkAndDomainPreferredAddress(),
+		Email:       c.Email,
+		Destination: c.Destination.NetworkAndDomainPreferredAddress(),
+		Sniffed:     c.Sniffed,
+		OutboundTag: c.OutboundTag,
+		Result:      c.Result.String(),
+		Start:       c.Start,
+		Uplink:      c.Uplink,
+		Downlink:    c.Downlink,
+	}
+}
+
+// handleConnections lists the active sessions on GET.
+func (s *server) handleConnections(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+		return
+	}
+
+	connections := s.setting.Tracker.List()
+
+	conns := make([]connection, 0, len(connections))
+	for _, c := range connections {
+		conns = append(conns, newConnection(c))
+	}
+
+	writeJSON(w, http.StatusOK, map[string][]connection{
+		"connections": conns,
+	})
+}
+
+// handleConnection returns the session of the id on GET and closes it on DELETE.
+func (s *server) handleConnection(w http.ResponseWriter, r *http.Request) {
+	s2 := strings.TrimPrefix(r.URL.Path, "/connections/")
+
+	n, err := strconv.ParseUint(s2, 10, 32)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, newError("invalid session id %s", s2).WithError(err))
+		return
+	}
+	id := session.ID(n)
+
+	switch r.Method {
+	case http.MethodGet:
+		c, ok := s.setting.Tracker.Get(id)
+		if !ok {
+			writeError(w, http.StatusNotFound, newError("session not found [%d]", id))
+			return
+		}
+
+		writeJSON(w, http.StatusOK, newConnection(c))
+	case http.MethodDelete:
+		if !s.setting.Tracker.Kill(id) {
+			writeError(w, http.StatusNotFound, newError("session not found [%d]", id))
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, newError("unsupported method %s", r.Method))
+	}
+}
@@ -1,6 +1,7 @@
 package dispatcher
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -8,22 +9,36 @@ import (
 	"v2ray.com/core/app/proxyman/outbound"
 	"v2ray.com/core/app/router"
 	sniffer_app "v2ray.com/core/app/sniffer"
+	"v2ray.com/core/app/stats"
+	"v2ray.com/core/app/tracker"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
 	sniffer_proto "v2ray.com/core/common/protocol/sniffer"
 	"v2ray.com/core/common/session"
 	"v2ray.com/core/transport"
 )
 
+const (
+	// sniffingMoreTimeout is how long more payload is waited for after the first one.
+	sniffingMoreTimeout = 200 * time.Millisecond
+	// sniffingSize is the most payload sniffed.
+	sniffingSize = 8 * 1024
+)
+
 type dispatcher struct {
 	handlers outbound.Manager
 	router   router.Matcher
+	stats    stats.Manager
+	tracker  tracker.Tracker
 }
 
-func NewDispatcher(handlers outbound.Manager, router router.Matcher) proxyman.Dispatcher {
+func NewDispatcher(handlers outbound.Manager, router router.Matcher, stats stats.Manager, tracker tracker.Tracker) proxyman.Dispatcher {
 	return &dispatcher{
 		handlers: handlers,
 		router:   router,
+		stats:    stats,
+		tracker:  tracker,
 	}
 }
 
@@ -32,7 +47,7 @@ func (d *dispatcher) Dispatch(content session.Content, address net.Address) (tra
 		ib, _ := content.GetInbound()
 
 		if tag, ok := d.router.MatchContent(content, address); ok {
-			newError("taking detour [%s] [%s] for [%s] [%s]", ib.Tag, tag, ib.Source.NetworkAndDomainPreferredAddress(), address.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("taking detour [%s] [%s] for [%s] [%s]", ib.Tag, tag, ib.Source.NetworkAndDomainPreferredAddress(), address.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 			return tag, nil
 		}
 		return "", newError("no matched outbound for [%s] [%s]", ib.Tag, ib.Source.NetworkAndDomainPreferredAddress())
@@ -47,28 +62,123 @@ func (d *dispatcher) Dispatch(content session.Content, address net.Address) (tra
 		return handler.Dispatch(content, address, link)
 	}
 
-	dispatch := func(address net.Address, outboundLink transport.Link, cReadWriter *cachedReadWriter) error {
-		address = cReadWriter.Sniff(address)
+	dispatch := func(address net.Address, outboundLink transport.Link, cReadWriter *cachedReadWriter, conn *tracker.Conn) error {
+		routeAddress, dialAddress := sniff(content, address, cReadWriter)
+		if sniffed, ok := content.GetSniffed(); ok {
+			conn.SetSniffed(sniffed.Domain)
+		}
 
-		tag, err := route(content, address)
+		tag, err := route(content, routeAddress)
 		if err != nil {
+			conn.SetResult(tracker.Rejected)
 			return err
 		}
+		conn.SetOutboundTag(tag)
 
-		return handle(tag, address, outboundLink)
+		if err := handle(tag, dialAddress, outboundLink); err != nil {
+			conn.SetResult(tracker.Failed)
+			return err
+		}
+		return nil
 	}
 
 	inboundLink, outboundLink, cReadWriter := newLink()
+	conn, inboundLink := d.tracker.Track(content, address, inboundLink)
+	inboundLink = d.countLink(content, inboundLink)
 
 	go func() {
-		if err := dispatch(address, outboundLink, cReadWriter); err != nil {
-			newError("failed to dispatch").WithError(err).AtDebug().Logging()
+		if err := dispatch(address, outboundLink, cReadWriter, conn); err != nil {
+			newError("failed to dispatch").WithError(err).WithSession(content).AtLevel(errors.Level(err)).Logging()
 		}
 	}()
 
 	return inboundLink, nil
 }
 
+// countLink counts the traffic of the inbound and its user, the inbound writes the uplink and reads the downlink.
+func (d *dispatcher) countLink(content session.Content, link transport.Link) transport.Link {
+	ib, _ := content.GetInbound()
+
+	var uplinks, downlinks []stats.Counter
+
+	if uplink, downlink, ok := d.stats.TrafficCounters(stats.Inbound, ib.Tag); ok {
+		uplinks, downlinks = append(uplinks, uplink), append(downlinks, downlink)
+	}
+	if uplink, downlink, ok := d.stats.TrafficCounters(stats.User, ib.Email); ok {
+		uplinks, downlinks = append(uplinks, uplink), append(downlinks, downlink)
+	}
+
+	link.Writer = stats.NewWriter(link.Writer, uplinks...)
+	link.Reader = stats.NewReader(link.Reader, downlinks...)
+
+	return link
+}
+
+// sniff returns the address for routing and the address for dialing.
+func sniff(content session.Content, address net.Address, cReadWriter *cachedReadWriter) (net.Address, net.Address) {
+	sniffing, ok := content.GetSniffing()
+	if !ok {
+		sniffing = session.DefaultSniffing()
+	}
+
+	if !sniffing.Enabled {
+		return address, address
+	}
+
+	result, err := cReadWriter.Sniff(address, sniffing.Timeout)
+	if errors.Is(err, buffer.ErrReadTimeout) {
+		newError("sniffing timeout after %s, routing [%s] by original address", sniffing.Timeout, address.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
+		return address, address
+	}
+	if err != nil {
+		newError("failed to sniff domain of [%s]", address.NetworkAndDomainPreferredAddress()).WithError(err).WithSession(content).AtDebug().Logging()
+		return address, address
+	}
+
+	newError("sniffed domain [%s] [%s] of [%s]", result.Domain, result.Protocol, address.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
+
+	content.SetSniffed(session.Sniffed{
+		Protocol: result.Protocol,
+		Domain:   result.Domain,
+		ALPN:     result.ALPN,
+		Method:   result.Method,
+	})
+
+	if !shouldOverride(sniffing, result) {
+		newError("not overriding [%s] by sniffed domain [%s]", address.NetworkAndDomainPreferredAddress(), result.Domain).WithSession(content).AtDebug().Logging()
+		return address, address
+	}
+
+	sniffed := result.AsAddress(address)
+
+	// a fake ip is never dialed
+	if sniffing.RouteOnly && result.Protocol != sniffer_proto.Fake {
+		return sniffed, address
+	}
+
+	return sniffed, sniffed
+}
+
+func shouldOverride(sniffing session.Sniffing, result sniffer_proto.SniffResult) bool {
+	if !result.IsValid() {
+		return false
+	}
+
+	for _, domain := range sniffing.ExcludedDomains {
+		if strings.EqualFold(result.Domain, domain) || strings.HasSuffix(strings.ToLower(result.Domain), "."+strings.ToLower(domain)) {
+			return false
+		}
+	}
+
+	for _, protocol := range sniffing.OverrideProtocols {
+		if protocol == result.Protocol {
+			return true
+		}
+	}
+
+	return false
+}
+
 func newLink() (transport.Link, transport.Link, *cachedReadWriter) {
 	inboundLink, outboundLink := transport.NewLink()
 
@@ -117,21 +227,25 @@ func (r *cachedReadWriter) readCache() buffer.MultiBuffer {
 	return mb
 }
 
-func (r *cachedReadWriter) Sniff(address net.Address) net.Address {
+// Sniff waits the first payload for timeout, or until it comes if timeout is zero.
+func (r *cachedReadWriter) Sniff(address net.Address, timeout time.Duration) (sniffer_proto.SniffResult, error) {
 	r.Lock()
 	defer r.Unlock()
 
 	result, err := func() (sniffer_proto.SniffResult, error) {
-		cache := func(b []byte) error {
-			mb, err := r.reader.ReadMultiBuffer()
+		cache := func(timeout time.Duration) error {
+			var mb buffer.MultiBuffer
+			var err error
+			if timeout > 0 {
+				mb, err = r.reader.ReadMultiBufferTimeout(timeout)
+			} else {
+				mb, err = r.reader.ReadMultiBuffer()
+			}
 			if err != nil {
 				defer buffer.ReleaseMulti(mb)
 				return err
 			}
 
-			n := mb.CopyBytes(b)
-			b = b[:n]
-
 			if r.data != nil {
 				r.data = buffer.MergeMulti(r.data, mb)
 			} else {
@@ -141,26 +255,44 @@ func (r *cachedReadWriter) Sniff(address net.Address) net.Address {
 			return nil
 		}
 
-		b := buffer.New()
-		defer b.Release()
+		sniff := func() (sniffer_proto.SniffResult, error) {
+			b := buffer.NewSize(sniffingSize)
+			defer b.Release()
+
+			n := r.data.CopyBytes(b.Extend(sniffingSize))
+			b.Resize(0, n)
 
-		rb := b.Extend(buffer.Size)
+			return sniffer_app.Sniff(b, address.IP, address.Network)
+		}
 
-		if err := cache(rb); err != nil {
+		if err := cache(timeout); err != nil {
 			return sniffer_proto.SniffResult{}, err
 		}
 
-		return sniffer_app.Sniff(b, address.IP, address.Network)
+		// a payload split over packets, like a large quic client hello, is read until the limits
+		deadline := time.Now().Add(sniffingMoreTimeout)
+		for {
+			result, err := sniff()
+			if err != sniffer_proto.ErrNeedMore || r.data.Len() >= sniffingSize {
+				return result, err
+			}
+
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return result, err
+			}
+
+			if cerr := cache(wait); cerr != nil {
+				return result, err
+			}
+		}
 	}()
 
 	if err != nil {
-		newError("failed to sniff domain of [%s]", address.NetworkAndDomainPreferredAddress()).WithError(err).AtDebug().Logging()
-	} else {
-		newError("sniffed domain [%s] [%d] of [%s]", result.Domain, result.Protocol, address.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
-		address = result.AsAddress(address)
+		return sniffer_proto.SniffResult{}, err
 	}
 
-	return address
+	return result, nil
 }
 
 func (r *cachedReadWriter) CloseWrite() error {
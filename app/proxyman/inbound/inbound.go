@@ -1,3 +1,36 @@
 package inbound
 
+import (
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/protocol"
+	"v2ray.com/core/proxy"
+)
+
+var (
+	errUsersUnsupported = newError("inbound has no users")
+)
+
+func addUser(server proxy.Server, user protocol.RequestUser) error {
+	if m, ok := server.(proxyman.UserManager); ok {
+		return m.AddUser(user)
+	}
+	return errUsersUnsupported
+}
+
+// closeServer closes the server if it holds the resources like the periodic tasks of vmess.
+func closeServer(server proxy.Server) error {
+	if closer, ok := server.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func removeUser(server proxy.Server, email string) error {
+	if m, ok := server.(proxyman.UserManager); ok {
+		return m.RemoveUser(email)
+	}
+	return errUsersUnsupported
+}
+
 //go:generate go run v2ray.com/core/common/errors/errorgen
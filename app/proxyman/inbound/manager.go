@@ -3,12 +3,20 @@ package inbound
 import (
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/common/cache"
+	"v2ray.com/core/common/net"
 )
 
+// Key is the key of an inbound handler, the tcp and udp handlers of an inbound share the tag.
+type Key struct {
+	Tag     string
+	Network net.Network
+}
+
 type Manager interface {
 	Get(interface{}) (proxyman.Inbound, bool)
 	Add(interface{}, proxyman.Inbound)
 	Delete(interface{})
+	Range(func(proxyman.Inbound) bool)
 }
 
 type manager struct {
@@ -37,3 +45,10 @@ func (m *manager) Delete(key interface{}) {
 		m.pool.Delete(key)
 	}
 }
+
+// Range calls the function for every handler until it returns false, the manager is locked while ranging.
+func (m *manager) Range(fn func(proxyman.Inbound) bool) {
+	m.pool.Range(func(_, handler interface{}) bool {
+		return fn(handler.(proxyman.Inbound))
+	})
+}
@@ -0,0 +1,141 @@
+package inbound
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+	"v2ray.com/core/common/signal"
+	"v2ray.com/core/transport"
+)
+
+// policyConn enforces the policy on an inbound connection.
+// The handshake timeout lasts until the request is dispatched, the idle timeout of the user level follows.
+type policyConn struct {
+	net.Conn
+
+	timer      signal.ActivityTimer
+	dispatched atomic.Bool
+
+	sync.Mutex
+	policy session.Policy
+}
+
+func newPolicyConn(content session.Content, conn net.Conn, policy session.Policy) *policyConn {
+	c := &policyConn{
+		Conn:   conn,
+		policy: policy,
+	}
+
+	c.timer = signal.CancelAfterInactivity(func() {
+		newError("closing inactive connection %s", conn.RemoteAddr().String()).WithSession(content).AtDebug().Logging()
+		_ = conn.Close()
+	}, policy.Handshake)
+
+	return c
+}
+
+func (c *policyConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 && c.dispatched.Load() {
+		c.timer.Update()
+	}
+	if err == io.EOF {
+		c.halfClose(func(p session.Policy) time.Duration {
+			return p.DownlinkOnly
+		})
+	}
+	return n, err
+}
+
+func (c *policyConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	// the replies of the handshake never extend it
+	if n > 0 && c.dispatched.Load() {
+		c.timer.Update()
+	}
+	return n, err
+}
+
+func (c *policyConn) Close() error {
+	_ = c.timer.Close()
+
+	return c.Conn.Close()
+}
+
+func (c *policyConn) dispatch(level uint32) {
+	c.Lock()
+	c.policy = c.policy.ForLevel(level)
+	policy := c.policy
+	c.Unlock()
+
+	c.dispatched.Store(true)
+	c.timer.SetTimeout(policy.ConnIdle)
+}
+
+// halfClose replaces the idle timeout once a side is closed, a zero timeout keeps it.
+func (c *policyConn) halfClose(timeoutFunc func(session.Policy) time.Duration) {
+	c.Lock()
+	timeout := timeoutFunc(c.policy)
+	c.Unlock()
+
+	if timeout > 0 && c.dispatched.Load() {
+		c.timer.SetTimeout(timeout)
+	}
+}
+
+// policyDispatcher switches the timeouts of the connection by the links it dispatches.
+type policyDispatcher struct {
+	proxyman.Dispatcher
+
+	conn *policyConn
+}
+
+func (d *policyDispatcher) Dispatch(content session.Content, address net.Address) (transport.Link, error) {
+	link, err := d.Dispatcher.Dispatch(content, address)
+	if err != nil {
+		return link, err
+	}
+
+	ib, _ := content.GetInbound()
+	d.conn.dispatch(ib.Level)
+
+	link.Reader = &policyReader{
+		PipeReader: link.Reader,
+		conn:       d.conn,
+	}
+
+	return link, nil
+}
+
+// policyReader reads the downlink, it is closed once the outbound is done.
+type policyReader struct {
+	transport.PipeReader
+
+	conn *policyConn
+}
+
+func (r *policyReader) ReadMultiBuffer() (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBuffer()
+	r.done(err)
+	return mb, err
+}
+
+func (r *policyReader) ReadMultiBufferTimeout(timeout time.Duration) (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBufferTimeout(timeout)
+	r.done(err)
+	return mb, err
+}
+
+func (r *policyReader) done(err error) {
+	if err == io.EOF {
+		r.conn.halfClose(func(p session.Policy) time.Duration {
+			return p.UplinkOnly
+		})
+	}
+}
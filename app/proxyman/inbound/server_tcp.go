@@ -2,8 +2,11 @@ package inbound
 
 import (
 	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/session"
+	"v2ray.com/core/common/signal"
 	"v2ray.com/core/proxy"
 	"v2ray.com/core/transport/internet"
 )
@@ -13,6 +16,8 @@ type TCPSetting struct {
 	Address      net.Address
 	Server       proxy.Server
 	ListenerFunc internet.ListenerFunc
+	Sniffing     session.Sniffing
+	Policy       session.Policy
 }
 
 type tcpInbound struct {
@@ -20,7 +25,10 @@ type tcpInbound struct {
 	tag        string
 	address    net.Address
 	server     proxy.Server
+	sniffing   session.Sniffing
+	policy     session.Policy
 	hub        internet.Listener
+	done       signal.Done
 }
 
 func NewTCPInbound(dispatcher proxyman.Dispatcher, setting TCPSetting) (proxyman.Inbound, error) {
@@ -34,7 +42,10 @@ func NewTCPInbound(dispatcher proxyman.Dispatcher, setting TCPSetting) (proxyman
 		tag:        setting.Tag,
 		address:    setting.Address,
 		server:     setting.Server,
+		sniffing:   setting.Sniffing,
+		policy:     setting.Policy,
 		hub:        hub,
+		done:       signal.NewDone(),
 	}
 
 	go h.handle()
@@ -45,6 +56,9 @@ func NewTCPInbound(dispatcher proxyman.Dispatcher, setting TCPSetting) (proxyman
 }
 
 func (h *tcpInbound) Close() error {
+	_ = h.done.Close()
+	_ = closeServer(h.server)
+
 	return h.hub.Close()
 }
 
@@ -52,39 +66,61 @@ func (h *tcpInbound) Tag() string {
 	return h.tag
 }
 
+func (h *tcpInbound) Network() net.Network {
+	return net.Network_TCP
+}
+
+func (h *tcpInbound) AddUser(user protocol.RequestUser) error {
+	return addUser(h.server, user)
+}
+
+func (h *tcpInbound) RemoveUser(email string) error {
+	return removeUser(h.server, email)
+}
+
 func (h *tcpInbound) handle() {
 	for {
 		select {
-		case conn := <-h.hub.Receive():
-			go func() {
-				if err := h.callback(conn); err != nil {
-					newError("failed to handle tcp conn").WithError(err).AtDebug().Logging()
-				}
-			}()
+		case conn, ok := <-h.hub.Receive():
+			// some hubs close the channel once closed
+			if !ok {
+				return
+			}
+			go h.callback(conn)
+		case <-h.done.Wait():
+			return
 		}
 	}
 }
 
-func (h *tcpInbound) callback(conn net.Conn) error {
-	dispatch := func(conn net.Conn) error {
-		defer func() {
-			_ = conn.Close()
-			newError("connection closed %s", conn.RemoteAddr().String()).AtDebug().Logging()
-		}()
-
-		content := session.NewContent()
-		content.SetID(session.NewID())
-		content.SetInbound(session.Inbound{
-			Source:  net.AddressFromAddr(conn.RemoteAddr()),
-			Gateway: h.address,
-			Tag:     h.tag,
-		})
-		defer func() {
-			_ = content.Close()
-		}()
-
-		return h.server.Process(content, conn, h.dispatcher)
-	}
+func (h *tcpInbound) callback(conn net.Conn) {
+	content := session.NewContent()
+	content.SetID(session.NewID())
+	content.SetInbound(session.Inbound{
+		Source:  net.AddressFromAddr(conn.RemoteAddr()),
+		Gateway: h.address,
+		Tag:     h.tag,
+	})
+	content.SetSniffing(h.sniffing)
+	content.SetPolicy(h.policy)
+	defer func() {
+		_ = content.Close()
+	}()
+	defer func() {
+		_ = conn.Close()
+		newError("connection closed %s", conn.RemoteAddr().String()).WithSession(content).AtDebug().Logging()
+	}()
 
-	return dispatch(conn)
+	pConn := newPolicyConn(content, conn, h.policy)
+	defer func() {
+		_ = pConn.Close()
+	}()
+	content.SetCloser(pConn)
+
+	if err := h.server.Process(content, pConn, &policyDispatcher{
+		Dispatcher: h.dispatcher,
+		conn:       pConn,
+	}); err != nil {
+		newError("failed to handle tcp conn").WithError(err).WithSession(content).AtLevel(errors.Level(err)).Logging()
+	}
 }
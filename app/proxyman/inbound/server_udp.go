@@ -2,26 +2,34 @@ package inbound
 
 import (
 	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/session"
+	"v2ray.com/core/common/signal"
 	"v2ray.com/core/proxy"
 	"v2ray.com/core/transport/internet"
 )
 
 type UDPSetting struct {
-	Tag     string
-	Address net.Address
-	Server  proxy.Server
-	HubFunc internet.HubFunc
+	Tag      string
+	Address  net.Address
+	Server   proxy.Server
+	HubFunc  internet.HubFunc
+	Sniffing session.Sniffing
+	Policy   session.Policy
 }
 
 type udpInbound struct {
 	dispatcher proxyman.Dispatcher
 
-	tag     string
-	address net.Address
-	server  proxy.Server
-	hub     internet.Hub
+	tag      string
+	address  net.Address
+	server   proxy.Server
+	sniffing session.Sniffing
+	policy   session.Policy
+	hub      internet.Hub
+	done     signal.Done
 }
 
 func NewUDPInbound(dispatcher proxyman.Dispatcher, setting UDPSetting) (proxyman.Inbound, error) {
@@ -35,7 +43,10 @@ func NewUDPInbound(dispatcher proxyman.Dispatcher, setting UDPSetting) (proxyman
 		tag:        setting.Tag,
 		address:    setting.Address,
 		server:     setting.Server,
+		sniffing:   setting.Sniffing,
+		policy:     setting.Policy,
 		hub:        hub,
+		done:       signal.NewDone(),
 	}
 
 	go h.handle()
@@ -46,6 +57,9 @@ func NewUDPInbound(dispatcher proxyman.Dispatcher, setting UDPSetting) (proxyman
 }
 
 func (h *udpInbound) Close() error {
+	_ = h.done.Close()
+	_ = closeServer(h.server)
+
 	return h.hub.Close()
 }
 
@@ -53,39 +67,61 @@ func (h *udpInbound) Tag() string {
 	return h.tag
 }
 
+func (h *udpInbound) Network() net.Network {
+	return net.Network_UDP
+}
+
+func (h *udpInbound) AddUser(user protocol.RequestUser) error {
+	return addUser(h.server, user)
+}
+
+func (h *udpInbound) RemoveUser(email string) error {
+	return removeUser(h.server, email)
+}
+
 func (h *udpInbound) handle() {
 	for {
 		select {
-		case conn := <-h.hub.Receive():
-			go func() {
-				if err := h.callback(conn); err != nil {
-					newError("failed to handle udp conn").WithError(err).AtDebug().Logging()
-				}
-			}()
+		case conn, ok := <-h.hub.Receive():
+			// some hubs close the channel once closed
+			if !ok {
+				return
+			}
+			go h.callback(conn)
+		case <-h.done.Wait():
+			return
 		}
 	}
 }
 
-func (h *udpInbound) callback(conn net.Conn) error {
-	dispatch := func(conn net.Conn) error {
-		defer func() {
-			_ = conn.Close()
-			newError("connection closed %s", conn.RemoteAddr().String()).AtDebug().Logging()
-		}()
-
-		content := session.NewContent()
-		content.SetID(session.NewID())
-		content.SetInbound(session.Inbound{
-			Source:  net.AddressFromAddr(conn.RemoteAddr()),
-			Gateway: h.address,
-			Tag:     h.tag,
-		})
-		defer func() {
-			_ = content.Close()
-		}()
-
-		return h.server.Process(content, conn, h.dispatcher)
+func (h *udpInbound) callback(conn net.Conn) {
+	content := session.NewContent()
+	content.SetID(session.NewID())
+	content.SetInbound(session.Inbound{
+		Source:  net.AddressFromAddr(conn.RemoteAddr()),
+		Gateway: h.address,
+		Tag:     h.tag,
+	})
+	content.SetSniffing(h.sniffing)
+	content.SetPolicy(h.policy)
+	defer func() {
+		_ = content.Close()
+	}()
+	defer func() {
+		_ = conn.Close()
+		newError("connection closed %s", conn.RemoteAddr().String()).WithSession(content).AtDebug().Logging()
+	}()
+
+	pConn := newPolicyConn(content, conn, h.policy)
+	defer func() {
+		_ = pConn.Close()
+	}()
+	content.SetCloser(pConn)
+
+	if err := h.server.Process(content, pConn, &policyDispatcher{
+		Dispatcher: h.dispatcher,
+		conn:       pConn,
+	}); err != nil {
+		newError("failed to handle udp conn").WithError(err).WithSession(content).AtLevel(errors.Level(err)).Logging()
 	}
-
-	return dispatch(conn)
 }
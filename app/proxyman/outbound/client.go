@@ -2,6 +2,7 @@ package outbound
 
 import (
 	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/common/io"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/session"
 	"v2ray.com/core/proxy"
@@ -43,7 +44,16 @@ func (h *outbound) Dispatch(content session.Content, address net.Address, link t
 		return err
 	}
 
-	return h.client.Process(content, address, link, tcpDialFunc, udpDialFunc)
+	// a content without the policy never closes the link
+	policy, _ := content.GetPolicy()
+	ib, _ := content.GetInbound()
+
+	pLink := newPolicyLink(content, policy.ForLevel(ib.Level), link)
+	defer func() {
+		_ = pLink.Close()
+	}()
+
+	return h.client.Process(content, address, pLink.Link(link), pLink.DialTCPFunc(tcpDialFunc), pLink.DialUDPFunc(udpDialFunc))
 }
 
 func (h *outbound) DialFunc(content session.Content, address net.Address) (internet.DialTCPFunc, internet.DialUDPFunc, error) {
@@ -54,6 +64,13 @@ func (h *outbound) DialFunc(content session.Content, address net.Address) (inter
 	return h.tcpDialFunc, h.udpDialFunc, nil
 }
 
+func (h *outbound) Close() error {
+	if closer, ok := h.client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 func (h *outbound) Tag() string {
 	return h.tag
 }
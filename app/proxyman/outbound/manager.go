@@ -9,6 +9,7 @@ type Manager interface {
 	Get(interface{}) (proxyman.Outbound, bool)
 	Add(interface{}, proxyman.Outbound)
 	Delete(interface{})
+	Range(func(proxyman.Outbound) bool)
 }
 
 type manager struct {
@@ -37,3 +38,10 @@ func (m *manager) Delete(key interface{}) {
 		m.pool.Delete(key)
 	}
 }
+
+// Range calls the function for every handler until it returns false, the manager is locked while ranging.
+func (m *manager) Range(fn func(proxyman.Outbound) bool) {
+	m.pool.Range(func(_, handler interface{}) bool {
+		return fn(handler.(proxyman.Outbound))
+	})
+}
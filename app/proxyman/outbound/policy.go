@@ -0,0 +1,199 @@
+package outbound
+
+import (
+	"sync"
+	"time"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+	"v2ray.com/core/common/signal"
+	"v2ray.com/core/transport"
+	"v2ray.com/core/transport/internet"
+)
+
+// policyLink enforces the policy on a dispatched link and the connections dialed for it.
+// Once it expires, the dialed connections and the downlink are closed.
+type policyLink struct {
+	policy session.Policy
+	timer  signal.ActivityTimer
+
+	sync.Mutex
+	conns  []io.Closer
+	closed bool
+}
+
+func newPolicyLink(content session.Content, policy session.Policy, link transport.Link) *policyLink {
+	l := &policyLink{
+		policy: policy,
+	}
+
+	l.timer = signal.CancelAfterInactivity(func() {
+		newError("closing inactive link").WithSession(content).AtDebug().Logging()
+		l.closeConns()
+		_ = link.Writer.Close()
+	}, policy.ConnIdle)
+
+	return l
+}
+
+func (l *policyLink) Close() error {
+	return l.timer.Close()
+}
+
+func (l *policyLink) Link(link transport.Link) transport.Link {
+	link.Reader = &policyReader{
+		PipeReader: link.Reader,
+		link:       l,
+	}
+
+	return link
+}
+
+func (l *policyLink) DialTCPFunc(dial internet.DialTCPFunc) internet.DialTCPFunc {
+	if dial == nil {
+		return nil
+	}
+
+	return func(src, dst net.Address) (net.Conn, error) {
+		conn, err := dial(src, dst)
+		if err != nil {
+			return nil, err
+		}
+
+		l.track(conn)
+
+		return &policyConn{
+			Conn: conn,
+			link: l,
+		}, nil
+	}
+}
+
+func (l *policyLink) DialUDPFunc(dial internet.DialUDPFunc) internet.DialUDPFunc {
+	if dial == nil {
+		return nil
+	}
+
+	return func(src net.Address) (net.PacketConn, error) {
+		conn, err := dial(src)
+		if err != nil {
+			return nil, err
+		}
+
+		l.track(conn)
+
+		return &policyPacketConn{
+			PacketConn: conn,
+			link:       l,
+		}, nil
+	}
+}
+
+func (l *policyLink) track(conn io.Closer) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.closed {
+		_ = conn.Close()
+		return
+	}
+
+	l.conns = append(l.conns, conn)
+}
+
+func (l *policyLink) closeConns() {
+	l.Lock()
+	defer l.Unlock()
+
+	l.closed = true
+
+	for _, conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.conns = nil
+}
+
+// halfClose replaces the idle timeout once a side is closed, a zero timeout keeps it.
+func (l *policyLink) halfClose(timeout time.Duration) {
+	if timeout > 0 {
+		l.timer.SetTimeout(timeout)
+	}
+}
+
+// policyReader reads the uplink, it is closed once the inbound is done.
+type policyReader struct {
+	transport.PipeReader
+
+	link *policyLink
+}
+
+func (r *policyReader) ReadMultiBuffer() (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBuffer()
+	r.update(mb, err)
+	return mb, err
+}
+
+func (r *policyReader) ReadMultiBufferTimeout(timeout time.Duration) (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBufferTimeout(timeout)
+	r.update(mb, err)
+	return mb, err
+}
+
+func (r *policyReader) update(mb buffer.MultiBuffer, err error) {
+	if !mb.IsEmpty() {
+		r.link.timer.Update()
+	}
+	if err == io.EOF {
+		r.link.halfClose(r.link.policy.DownlinkOnly)
+	}
+}
+
+// policyConn is a connection dialed by the outbound, it is closed once the target is done.
+type policyConn struct {
+	net.Conn
+
+	link *policyLink
+}
+
+func (c *policyConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.link.timer.Update()
+	}
+	if err == io.EOF {
+		c.link.halfClose(c.link.policy.UplinkOnly)
+	}
+	return n, err
+}
+
+func (c *policyConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.link.timer.Update()
+	}
+	return n, err
+}
+
+type policyPacketConn struct {
+	net.PacketConn
+
+	link *policyLink
+}
+
+func (c *policyPacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := c.PacketConn.ReadFrom(b)
+	if n > 0 {
+		c.link.timer.Update()
+	}
+	return n, addr, err
+}
+
+func (c *policyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := c.PacketConn.WriteTo(b, addr)
+	if n > 0 {
+		c.link.timer.Update()
+	}
+	return n, err
+}
@@ -0,0 +1,32 @@
+package outbound
+
+import (
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/app/stats"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+	"v2ray.com/core/transport"
+)
+
+// statsOutbound counts the traffic of an outbound, it reads the uplink and writes the downlink.
+type statsOutbound struct {
+	proxyman.Outbound
+
+	stats stats.Manager
+}
+
+func NewStatsOutbound(handler proxyman.Outbound, stats stats.Manager) proxyman.Outbound {
+	return &statsOutbound{
+		Outbound: handler,
+		stats:    stats,
+	}
+}
+
+func (h *statsOutbound) Dispatch(content session.Content, address net.Address, link transport.Link) error {
+	if uplink, downlink, ok := h.stats.TrafficCounters(stats.Outbound, h.Tag()); ok {
+		link.Reader = stats.NewReader(link.Reader, uplink)
+		link.Writer = stats.NewWriter(link.Writer, downlink)
+	}
+
+	return h.Outbound.Dispatch(content, address, link)
+}
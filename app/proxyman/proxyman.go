@@ -2,6 +2,7 @@ package proxyman
 
 import (
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/session"
 	"v2ray.com/core/transport"
 )
@@ -10,10 +11,21 @@ type Inbound interface {
 	Close() error
 
 	Tag() string
+	Network() net.Network
+
+	UserManager
+}
+
+// UserManager adds and removes the users of an inbound at runtime, the users are keyed by email.
+type UserManager interface {
+	AddUser(protocol.RequestUser) error
+	RemoveUser(email string) error
 }
 
 type Outbound interface {
 	Dispatch(session.Content, net.Address, transport.Link) error
+	// Close releases the handler once it is replaced or removed, the mux connections are closed with their sessions.
+	Close() error
 
 	Tag() string
 }
@@ -14,13 +14,18 @@ const (
 type DefaultLookup session.Lookup
 
 func (d DefaultLookup) Match(condition router.Condition) bool {
-	if !condition.MatchString(LookupDomain, d.Domain) {
-		return false
-	}
-	if !condition.MatchString(LookupInboundTag, d.InboundTag) {
+	return condition.Match(d)
+}
+
+func (d DefaultLookup) MatchBody(body router.ConditionBody) bool {
+	switch body.Name {
+	case LookupDomain:
+		return body.MatchString(d.Domain)
+	case LookupInboundTag:
+		return body.MatchString(d.InboundTag)
+	default:
 		return false
 	}
-	return true
 }
 
 func BuildDefaultLookup(lookup session.Lookup) DefaultLookup {
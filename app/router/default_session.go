@@ -1,7 +1,11 @@
 package router
 
 import (
+	"sync"
+	"time"
+
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/process"
 	"v2ray.com/core/common/router"
 	"v2ray.com/core/common/session"
 )
@@ -19,48 +23,107 @@ const (
 	DstPort
 
 	InboundTag
+
+	ProcessUID
+	ProcessName
+	ProcessPath
+
+	Time
+
+	Protocol
+	ALPN
+	Method
 )
 
+// LookupDstIPFunc returns the resolved ips of the destination domain, it is resolved on demand.
+type LookupDstIPFunc = func() []net.IP
+
+// ProcessFunc returns the local process owning the source socket, it is resolved on demand.
+type ProcessFunc = func() (process.Info, bool)
+
 type DefaultContent struct {
 	SrcNetwork, DstNetwork net.Network
 	SrcIP, DstIP           net.IP
 	DstDomain              net.Domain
 	SrcPort, DstPort       net.Port
 	InboundTag             string
+	LookupDstIP            LookupDstIPFunc
+	Process                ProcessFunc
+	Time                   time.Time
+	Protocol               string
+	ALPN                   []string
+	Method                 string
 }
 
 func (d DefaultContent) Match(condition router.Condition) bool {
-	if !condition.MatchString(SrcNetwork, d.SrcNetwork.This()) {
-		return false
-	}
-	if !condition.MatchString(DstNetwork, d.DstNetwork.This()) {
-		return false
-	}
-	if !condition.MatchIP(SrcIP, d.SrcIP) && !condition.MatchString(SrcIP, d.SrcIP.String()) {
-		return false
-	}
-	if !condition.MatchIP(DstIP, d.DstIP) && !condition.MatchString(DstIP, d.DstIP.String()) {
-		return false
-	}
-	if !condition.MatchString(DstDomain, d.DstDomain.This()) {
-		return false
-	}
-	if !condition.MatchString(SrcPort, d.SrcPort.String()) {
+	return condition.Match(d)
+}
+
+func (d DefaultContent) MatchBody(body router.ConditionBody) bool {
+	switch body.Name {
+	case SrcNetwork:
+		return body.MatchString(d.SrcNetwork.This())
+	case DstNetwork:
+		return body.MatchString(d.DstNetwork.This())
+	case SrcIP:
+		return body.MatchIP(d.SrcIP) || body.MatchString(d.SrcIP.String())
+	case DstIP:
+		if len(d.DstIP) == 0 && d.LookupDstIP != nil {
+			for _, ip := range d.LookupDstIP() {
+				if body.MatchIP(ip) || body.MatchString(ip.String()) {
+					return true
+				}
+			}
+			return false
+		}
+		return body.MatchIP(d.DstIP) || body.MatchString(d.DstIP.String())
+	case DstDomain:
+		return body.MatchString(d.DstDomain.This())
+	case SrcPort:
+		return body.MatchString(d.SrcPort.String())
+	case DstPort:
+		return body.MatchString(d.DstPort.String())
+	case InboundTag:
+		return body.MatchString(d.InboundTag)
+	case ProcessUID:
+		info, ok := d.process()
+		return ok && body.MatchString(info.UIDString())
+	case ProcessName:
+		info, ok := d.process()
+		return ok && body.MatchString(info.Name)
+	case ProcessPath:
+		info, ok := d.process()
+		return ok && body.MatchString(info.Path)
+	case Time:
+		return body.MatchTime(d.Time)
+	case Protocol:
+		return len(d.Protocol) > 0 && body.MatchString(d.Protocol)
+	case ALPN:
+		for _, alpn := range d.ALPN {
+			if body.MatchString(alpn) {
+				return true
+			}
+		}
 		return false
-	}
-	if !condition.MatchString(DstPort, d.DstPort.String()) {
+	case Method:
+		return len(d.Method) > 0 && body.MatchString(d.Method)
+	default:
 		return false
 	}
-	if !condition.MatchString(InboundTag, d.InboundTag) {
-		return false
+}
+
+func (d DefaultContent) process() (process.Info, bool) {
+	if d.Process == nil {
+		return process.Info{}, false
 	}
-	return true
+	return d.Process()
 }
 
 func BuildDefaultContent(content session.Content, address net.Address) DefaultContent {
 	ib, _ := content.GetInbound()
+	sniffed, sniffedOK := content.GetSniffed()
 
-	return DefaultContent{
+	dc := DefaultContent{
 		SrcNetwork: ib.Source.Network,
 		DstNetwork: address.Network,
 		SrcIP:      ib.Source.IP,
@@ -69,5 +132,32 @@ func BuildDefaultContent(content session.Content, address net.Address) DefaultCo
 		SrcPort:    ib.Source.Port,
 		DstPort:    address.Port,
 		InboundTag: ib.Tag,
+		Process:    buildProcessFunc(content, ib.Source),
+	}
+
+	if sniffedOK {
+		dc.Protocol = sniffed.Protocol.String()
+		dc.ALPN = sniffed.ALPN
+		dc.Method = sniffed.Method
+	}
+
+	return dc
+}
+
+func buildProcessFunc(content session.Content, source net.Address) ProcessFunc {
+	var once sync.Once
+	var info process.Info
+	var found bool
+
+	return func() (process.Info, bool) {
+		once.Do(func() {
+			var err error
+			if info, err = process.FindProcess(source); err != nil {
+				newError("failed to find process of [%s]", source.NetworkAndIPAddress()).WithError(err).WithSession(content).AtDebug().Logging()
+				return
+			}
+			found = true
+		})
+		return info, found
 	}
 }
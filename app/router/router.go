@@ -1,35 +1,160 @@
 package router
 
 import (
+	"sync"
+	"time"
+
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/router"
 	"v2ray.com/core/common/session"
 )
 
+// DomainStrategy is how the ip rules are matched for a domain destination.
+type DomainStrategy byte
+
+const (
+	// AsIs never resolves the domain.
+	AsIs DomainStrategy = iota
+	// IPIfNonMatch resolves the domain and matches again if no rule matches.
+	IPIfNonMatch
+	// IPOnDemand resolves the domain once an ip rule is matched.
+	IPOnDemand
+)
+
+// LookupIPFunc resolves the domain of a lookup by the internal nameserver.
+type LookupIPFunc = func(session.Lookup) ([]net.IP, error)
+
 type Matcher interface {
 	MatchContent(session.Content, net.Address) (string, bool)
 	MatchLookup(session.Lookup) (string, bool)
 }
 
+// NowFunc returns the time the time conditions are matched with.
+type NowFunc = func() time.Time
+
+type MatcherSetting struct {
+	DomainStrategy DomainStrategy
+	LookupIPFunc   LookupIPFunc
+	// NowFunc is time.Now if nil.
+	NowFunc NowFunc
+}
+
 type matcher struct {
-	rule []router.Rule
+	rule           []router.Rule
+	domainStrategy DomainStrategy
+	lookupIPFunc   LookupIPFunc
+	nowFunc        NowFunc
+	// dstIPRule tells if a rule has a dstIP condition, even a negated or grouped one.
+	dstIPRule []bool
 }
 
 func NewMatcher(rule ...router.Rule) Matcher {
+	return NewMatcherWithSetting(MatcherSetting{}, rule...)
+}
+
+func NewMatcherWithSetting(setting MatcherSetting, rule ...router.Rule) Matcher {
+	domainStrategy := setting.DomainStrategy
+	if setting.LookupIPFunc == nil {
+		domainStrategy = AsIs
+	}
+
+	nowFunc := setting.NowFunc
+	if nowFunc == nil {
+		nowFunc = time.Now
+	}
+
+	dstIPRule := make([]bool, len(rule))
+	for i, r := range rule {
+		dstIPRule[i] = hasDstIP(r.Condition)
+	}
+
 	return &matcher{
-		rule: rule,
+		rule:           rule,
+		domainStrategy: domainStrategy,
+		lookupIPFunc:   setting.LookupIPFunc,
+		nowFunc:        nowFunc,
+		dstIPRule:      dstIPRule,
 	}
 }
 
 func (m *matcher) MatchContent(content session.Content, address net.Address) (string, bool) {
-	for _, rule := range m.rule {
-		if matchContentRule(rule.Condition, content, address) {
+	dc := BuildDefaultContent(content, address)
+	dc.Time = m.nowFunc()
+
+	resolvable := address.IsDomainHost() && address.Domain.IsValid()
+
+	if resolvable && m.domainStrategy == IPOnDemand {
+		dc.LookupDstIP = m.buildLookupDstIPFunc(content, address)
+	}
+
+	// the dstIP rules are left to the resolved pass, a negated one would match the unknown ip
+	skipDstIP := resolvable && m.domainStrategy == IPIfNonMatch
+
+	if tag, ok := m.matchContent(dc, skipDstIP); ok {
+		return tag, true
+	}
+
+	if skipDstIP {
+		dc.LookupDstIP = m.buildLookupDstIPFunc(content, address)
+
+		if tag, ok := m.matchContent(dc, false); ok {
+			newError("matched [%s] by resolved ip of [%s]", tag, address.NetworkAndDomainPreferredAddress()).WithSession(content).AtDebug().Logging()
+			return tag, true
+		}
+	}
+
+	return "", false
+}
+
+func (m *matcher) matchContent(dc DefaultContent, skipDstIP bool) (string, bool) {
+	for i, rule := range m.rule {
+		if skipDstIP && m.dstIPRule[i] {
+			continue
+		}
+		if dc.Match(rule.Condition) {
 			return rule.OutboundTag, true
 		}
 	}
 	return "", false
 }
 
+func hasDstIP(condition router.Condition) bool {
+	for _, body := range condition {
+		if !body.IsGroup() && body.Name == DstIP {
+			return true
+		}
+		if hasDstIP(body.All) {
+			return true
+		}
+		for _, cond := range body.Any {
+			if hasDstIP(cond) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *matcher) buildLookupDstIPFunc(content session.Content, address net.Address) LookupDstIPFunc {
+	var once sync.Once
+	var ips []net.IP
+
+	return func() []net.IP {
+		once.Do(func() {
+			ib, _ := content.GetInbound()
+
+			var err error
+			if ips, err = m.lookupIPFunc(session.Lookup{
+				Domain:     address.Domain.This(),
+				InboundTag: ib.Tag,
+			}); err != nil {
+				newError("failed to resolve [%s] for ip rules", address.Domain).WithError(err).WithSession(content).AtDebug().Logging()
+			}
+		})
+		return ips
+	}
+}
+
 func (m *matcher) MatchLookup(lookup session.Lookup) (string, bool) {
 	for _, rule := range m.rule {
 		if matchLookupRule(rule.Condition, lookup) {
@@ -39,12 +164,9 @@ func (m *matcher) MatchLookup(lookup session.Lookup) (string, bool) {
 	return "", false
 }
 
-func matchContentRule(condition router.Condition, content session.Content, address net.Address) bool {
-	dc := BuildDefaultContent(content, address)
-	return dc.Match(condition)
-}
-
 func matchLookupRule(condition router.Condition, lookup session.Lookup) bool {
 	dc := BuildDefaultLookup(lookup)
 	return dc.Match(condition)
 }
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -0,0 +1,40 @@
+package router
+
+import (
+	"sync/atomic"
+
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+)
+
+// SwitchMatcher matches by the matcher swapped in last, so that the rules are replaced at runtime.
+type SwitchMatcher struct {
+	matcher atomic.Value
+}
+
+// matcherBox keeps the type stored in the atomic value the same.
+type matcherBox struct {
+	Matcher
+}
+
+func NewSwitchMatcher(matcher Matcher) *SwitchMatcher {
+	m := &SwitchMatcher{}
+	m.Swap(matcher)
+	return m
+}
+
+func (m *SwitchMatcher) Swap(matcher Matcher) {
+	m.matcher.Store(matcherBox{matcher})
+}
+
+func (m *SwitchMatcher) load() Matcher {
+	return m.matcher.Load().(matcherBox).Matcher
+}
+
+func (m *SwitchMatcher) MatchContent(content session.Content, address net.Address) (string, bool) {
+	return m.load().MatchContent(content, address)
+}
+
+func (m *SwitchMatcher) MatchLookup(lookup session.Lookup) (string, bool) {
+	return m.load().MatchLookup(lookup)
+}
@@ -3,14 +3,17 @@ package sniffer
 import (
 	"v2ray.com/core/common/buffer"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/bittorrent"
+	"v2ray.com/core/common/protocol/dns"
 	"v2ray.com/core/common/protocol/http"
 	"v2ray.com/core/common/protocol/quic"
 	"v2ray.com/core/common/protocol/sniffer"
+	"v2ray.com/core/common/protocol/ssh"
+	"v2ray.com/core/common/protocol/stun"
 	"v2ray.com/core/common/protocol/tls"
 )
 
 var (
-	errIsIP  = newError("is ip")
 	errOnAll = newError("failed on all")
 )
 
@@ -21,9 +24,17 @@ var (
 		net.Network_TCP: {
 			http.NewSniffer(),
 			tls.NewSniffer(),
+			http.NewH2CSniffer(),
+			bittorrent.NewSniffer(),
+			ssh.NewSniffer(),
+			dns.NewSniffer(net.Network_TCP),
 		},
 		net.Network_UDP: {
 			quic.NewSniffer(),
+			tls.NewDTLSSniffer(),
+			stun.NewSniffer(),
+			bittorrent.NewUDPSniffer(),
+			dns.NewSniffer(net.Network_UDP),
 		},
 	}
 )
@@ -35,13 +46,24 @@ func Sniff(b *buffer.Buffer, ip net.IP, network net.Network) (sniffer.SniffResul
 		}
 	}
 
+	needMore := false
+
 	for _, s := range dataSniffers[network] {
-		if result, err := s.Sniff(b, ip); err == nil {
-			if isDomainHost(result.Domain) {
-				return result, nil
+		result, err := s.Sniff(b, ip)
+		if err == nil {
+			// the protocol is kept without a domain, like bittorrent or http to an ip
+			if result.IsValid() && !isDomainHost(result.Domain) {
+				result.Domain = ""
 			}
-			return sniffer.SniffResult{}, errIsIP
+			return result, nil
 		}
+		if err == sniffer.ErrNeedMore {
+			needMore = true
+		}
+	}
+
+	if needMore {
+		return sniffer.SniffResult{}, sniffer.ErrNeedMore
 	}
 
 	return sniffer.SniffResult{}, errOnAll
@@ -0,0 +1,10 @@
+package stats
+
+import "v2ray.com/core/common/errors"
+
+type errorPathHolder struct {
+}
+
+func newError(msg string, args ...interface{}) errors.Error {
+	return errors.New(msg, args...).WithPath(errorPathHolder{})
+}
@@ -0,0 +1,69 @@
+package stats
+
+import (
+	"time"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/transport"
+)
+
+// NewReader counts the bytes read from the reader by the counters.
+func NewReader(reader transport.PipeReader, counters ...Counter) transport.PipeReader {
+	if len(counters) == 0 {
+		return reader
+	}
+
+	return &countingReader{
+		PipeReader: reader,
+		counters:   counters,
+	}
+}
+
+// NewWriter counts the bytes written to the writer by the counters.
+func NewWriter(writer transport.PipeWriteCloser, counters ...Counter) transport.PipeWriteCloser {
+	if len(counters) == 0 {
+		return writer
+	}
+
+	return &countingWriter{
+		PipeWriteCloser: writer,
+		counters:        counters,
+	}
+}
+
+type countingReader struct {
+	transport.PipeReader
+
+	counters []Counter
+}
+
+func (r *countingReader) ReadMultiBuffer() (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBuffer()
+	add(r.counters, mb)
+	return mb, err
+}
+
+func (r *countingReader) ReadMultiBufferTimeout(timeout time.Duration) (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBufferTimeout(timeout)
+	add(r.counters, mb)
+	return mb, err
+}
+
+type countingWriter struct {
+	transport.PipeWriteCloser
+
+	counters []Counter
+}
+
+func (w *countingWriter) WriteMultiBuffer(mb buffer.MultiBuffer) error {
+	add(w.counters, mb)
+	return w.PipeWriteCloser.WriteMultiBuffer(mb)
+}
+
+func add(counters []Counter, mb buffer.MultiBuffer) {
+	if n := int64(mb.Len()); n > 0 {
+		for _, c := range counters {
+			c.Add(n)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package stats
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	Inbound  = "inbound"
+	Outbound = "outbound"
+	User     = "user"
+
+	Uplink   = "uplink"
+	Downlink = "downlink"
+
+	separator = ">>>"
+	traffic   = "traffic"
+)
+
+// TrafficName returns the name of a traffic counter, like inbound>>>socks-in>>>traffic>>>uplink.
+func TrafficName(kind, name, direction string) string {
+	return strings.Join([]string{kind, name, traffic, direction}, separator)
+}
+
+// Counter is a named atomic counter.
+type Counter interface {
+	Value() int64
+	Add(int64) int64
+	// Reset sets the counter to zero and returns the value before.
+	Reset() int64
+}
+
+type counter struct {
+	value int64
+}
+
+// NewCounter returns a counter out of any manager.
+func NewCounter() Counter {
+	return &counter{}
+}
+
+func (c *counter) Value() int64 {
+	return atomic.LoadInt64(&c.value)
+}
+
+func (c *counter) Add(delta int64) int64 {
+	return atomic.AddInt64(&c.value, delta)
+}
+
+func (c *counter) Reset() int64 {
+	return atomic.SwapInt64(&c.value, 0)
+}
+
+type Setting struct {
+	Inbound  bool
+	Outbound bool
+	User     bool
+}
+
+type Manager interface {
+	// Counter returns the counter of the name, it is registered if absent.
+	Counter(string) Counter
+	Get(string) (Counter, bool)
+	// TrafficCounters returns the uplink and downlink counters of an inbound/outbound/user, if they are enabled.
+	TrafficCounters(kind, name string) (Counter, Counter, bool)
+	// Snapshot returns the values of the counters with the prefix, they are reset with reset.
+	Snapshot(prefix string, reset bool) map[string]int64
+}
+
+type manager struct {
+	setting Setting
+
+	sync.RWMutex
+	counters map[string]*counter
+}
+
+func NewManager(setting Setting) Manager {
+	return &manager{
+		setting:  setting,
+		counters: make(map[string]*counter),
+	}
+}
+
+func (m *manager) Counter(name string) Counter {
+	if c, ok := m.Get(name); ok {
+		return c
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	c, ok := m.counters[name]
+	if !ok {
+		c = &counter{}
+		m.counters[name] = c
+	}
+	return c
+}
+
+func (m *manager) Get(name string) (Counter, bool) {
+	m.RLock()
+	defer m.RUnlock()
+
+	c, ok := m.counters[name]
+	if !ok {
+		return nil, false
+	}
+	return c, true
+}
+
+func (m *manager) TrafficCounters(kind, name string) (Counter, Counter, bool) {
+	if len(name) == 0 || !m.enabled(kind) {
+		return nil, nil, false
+	}
+
+	return m.Counter(TrafficName(kind, name, Uplink)), m.Counter(TrafficName(kind, name, Downlink)), true
+}
+
+func (m *manager) enabled(kind string) bool {
+	switch kind {
+	case Inbound:
+		return m.setting.Inbound
+	case Outbound:
+		return m.setting.Outbound
+	case User:
+		return m.setting.User
+	default:
+		return false
+	}
+}
+
+func (m *manager) Snapshot(prefix string, reset bool) map[string]int64 {
+	m.RLock()
+	defer m.RUnlock()
+
+	values := make(map[string]int64)
+	for name, c := range m.counters {
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		if reset {
+			values[name] = c.Reset()
+		} else {
+			values[name] = c.Value()
+		}
+	}
+	return values
+}
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -0,0 +1,347 @@
+package tracker
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"v2ray.com/core/app/stats"
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
+	"v2ray.com/core/transport"
+)
+
+// Connection is a snapshot of a tracked session.
+type Connection struct {
+	ID          session.ID
+	InboundTag  string
+	Source      net.Address
+	Email       string
+	Destination net.Address
+	// Sniffed is the sniffed domain, it is empty if not sniffed.
+	Sniffed     string
+	OutboundTag string
+	Result      Result
+	Start       time.Time
+	// End is zero until the session is removed.
+	End      time.Time
+	Uplink   int64
+	Downlink int64
+}
+
+// Result is how the session is dispatched.
+type Result byte
+
+const (
+	// Accepted is a session handled by its outbound.
+	Accepted Result = iota
+	// Rejected is a session without a matched outbound.
+	Rejected
+	// Failed is a session its outbound failed to handle.
+	Failed
+)
+
+func (r Result) String() string {
+	switch r {
+	case Accepted:
+		return "accepted"
+	case Rejected:
+		return "rejected"
+	case Failed:
+		return "failed"
+	default:
+		return "unknown"
+	}
+}
+
+// Conn is a tracked session, its destination and outbound are updated while dispatching.
+// The dispatches of a session share the Conn, such as the sub-streams of mux and the packets of udp,
+// so its destination, sniffed domain and outbound are of the latest dispatch.
+type Conn struct {
+	sync.Mutex
+	connection Connection
+	links      map[*link]struct{}
+	// closer closes the inbound connection, it is nil if the inbound sets none.
+	closer io.Closer
+
+	uplink   stats.Counter
+	downlink stats.Counter
+}
+
+func (c *Conn) SetSniffed(domain string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.connection.Sniffed = domain
+}
+
+func (c *Conn) SetOutboundTag(tag string) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.connection.OutboundTag = tag
+}
+
+func (c *Conn) SetResult(result Result) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.connection.Result = result
+}
+
+func (c *Conn) snapshot() Connection {
+	c.Lock()
+	defer c.Unlock()
+
+	connection := c.connection
+	connection.Uplink = c.uplink.Value()
+	connection.Downlink = c.downlink.Value()
+	return connection
+}
+
+// close closes the inbound connection and the both pipes of the links, the inbound and outbound of the session end.
+func (c *Conn) close() {
+	c.Lock()
+	links := make([]transport.Link, 0, len(c.links))
+	for l := range c.links {
+		links = append(links, l.link)
+	}
+	c.Unlock()
+
+	// an inbound blocked reading the client ends only once its connection is closed
+	if c.closer != nil {
+		_ = c.closer.Close()
+	}
+
+	for _, link := range links {
+		_ = link.Writer.Close()
+		if closer, ok := link.Reader.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// link is the inbound link of a dispatch, it is dropped once the inbound closes the uplink and reads the downlink to the end.
+type link struct {
+	link transport.Link
+
+	writeDone bool
+	readDone  bool
+}
+
+func (c *Conn) done(l *link, write bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	if write {
+		l.writeDone = true
+	} else {
+		l.readDone = true
+	}
+	if l.writeDone && l.readDone {
+		delete(c.links, l)
+	}
+}
+
+type linkWriter struct {
+	transport.PipeWriteCloser
+
+	conn *Conn
+	link *link
+	once sync.Once
+}
+
+func (w *linkWriter) Close() error {
+	err := w.PipeWriteCloser.Close()
+	w.once.Do(func() {
+		w.conn.done(w.link, true)
+	})
+	return err
+}
+
+type linkReader struct {
+	transport.PipeReader
+
+	conn *Conn
+	link *link
+	once sync.Once
+}
+
+func (r *linkReader) ReadMultiBuffer() (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBuffer()
+	r.check(err)
+	return mb, err
+}
+
+func (r *linkReader) ReadMultiBufferTimeout(timeout time.Duration) (buffer.MultiBuffer, error) {
+	mb, err := r.PipeReader.ReadMultiBufferTimeout(timeout)
+	r.check(err)
+	return mb, err
+}
+
+func (r *linkReader) check(err error) {
+	if err == nil || errors.Is(err, buffer.ErrReadTimeout) {
+		return
+	}
+	r.once.Do(func() {
+		r.conn.done(r.link, false)
+	})
+}
+
+type Tracker interface {
+	// Track registers the inbound link of the session and returns it counted,
+	// the session is removed once its content is closed. A session dispatching again shares the Conn,
+	// and the link of a dispatch is dropped once it ends.
+	Track(session.Content, net.Address, transport.Link) (*Conn, transport.Link)
+	List() []Connection
+	Get(session.ID) (Connection, bool)
+	// Kill closes the links of the session.
+	Kill(session.ID) bool
+}
+
+type Setting struct {
+	// OnRemove is called with the ended session once it is removed.
+	OnRemove func(Connection)
+}
+
+type tracker struct {
+	onRemove func(Connection)
+
+	sync.RWMutex
+	conns map[session.ID]*Conn
+}
+
+func NewTracker(setting Setting) Tracker {
+	return &tracker{
+		onRemove: setting.OnRemove,
+		conns:    make(map[session.ID]*Conn),
+	}
+}
+
+func (t *tracker) Track(content session.Content, address net.Address, inboundLink transport.Link) (*Conn, transport.Link) {
+	id, ok := content.GetID()
+	if !ok {
+		return &Conn{
+			links:    make(map[*link]struct{}),
+			uplink:   stats.NewCounter(),
+			downlink: stats.NewCounter(),
+		}, inboundLink
+	}
+
+	t.Lock()
+	c, ok := t.conns[id]
+	if !ok {
+		ib, _ := content.GetInbound()
+		closer, _ := content.GetCloser()
+
+		c = &Conn{
+			connection: Connection{
+				ID:         id,
+				InboundTag: ib.Tag,
+				Source:     ib.Source,
+				Email:      ib.Email,
+				Start:      time.Now(),
+			},
+			links:    make(map[*link]struct{}),
+			closer:   closer,
+			uplink:   stats.NewCounter(),
+			downlink: stats.NewCounter(),
+		}
+		t.conns[id] = c
+	}
+	t.Unlock()
+
+	if !ok {
+		content.OnClose(func() {
+			t.remove(id, c)
+		})
+	}
+
+	l := &link{
+		link: inboundLink,
+	}
+
+	c.Lock()
+	c.connection.Destination = address
+	c.connection.Sniffed = ""
+	c.links[l] = struct{}{}
+	c.Unlock()
+
+	inboundLink.Writer = &linkWriter{
+		PipeWriteCloser: stats.NewWriter(inboundLink.Writer, c.uplink),
+		conn:            c,
+		link:            l,
+	}
+	inboundLink.Reader = &linkReader{
+		PipeReader: stats.NewReader(inboundLink.Reader, c.downlink),
+		conn:       c,
+		link:       l,
+	}
+
+	return c, inboundLink
+}
+
+func (t *tracker) remove(id session.ID, c *Conn) {
+	t.Lock()
+	delete(t.conns, id)
+	t.Unlock()
+
+	if t.onRemove != nil {
+		connection := c.snapshot()
+		connection.End = time.Now()
+
+		t.onRemove(connection)
+	}
+}
+
+func (t *tracker) List() []Connection {
+	t.RLock()
+	conns := make([]*Conn, 0, len(t.conns))
+	for _, c := range t.conns {
+		conns = append(conns, c)
+	}
+	t.RUnlock()
+
+	connections := make([]Connection, 0, len(conns))
+	for _, c := range conns {
+		connections = append(connections, c.snapshot())
+	}
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].Start.Before(connections[j].Start)
+	})
+
+	return connections
+}
+
+func (t *tracker) Get(id session.ID) (Connection, bool) {
+	t.RLock()
+	c, ok := t.conns[id]
+	t.RUnlock()
+
+	if !ok {
+		return Connection{}, false
+	}
+	return c.snapshot(), true
+}
+
+func (t *tracker) Kill(id session.ID) bool {
+	t.RLock()
+	c, ok := t.conns[id]
+	t.RUnlock()
+
+	if !ok {
+		return false
+	}
+
+	c.close()
+
+	newError("killed session [%d]", id).AtInfo().Logging()
+
+	return true
+}
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -3,18 +3,22 @@ package assets
 import (
 	"os"
 	"path/filepath"
+	"sort"
 
 	"v2ray.com/core/common/io"
 )
 
 var (
 	ConfReadOption = struct {
-		WorkingPath, FilePath, Filename, FileSuffix string
+		WorkingPath, FilePath, Filename, DirPath string
+		// FileSuffixes are the suffixes of the conf files, the conf file is the first existing one.
+		FileSuffixes []string
 	}{
-		WorkingPath: getConfExecutableDir(),
-		FilePath:    "conf",
-		Filename:    "conf",
-		FileSuffix:  ".json",
+		WorkingPath:  getConfExecutableDir(),
+		FilePath:     "conf",
+		Filename:     "conf",
+		DirPath:      "conf.d",
+		FileSuffixes: []string{".json", ".jsonc", ".yaml", ".yml", ".toml"},
 	}
 )
 
@@ -26,6 +30,12 @@ func getConfExecutableDir() string {
 	return filepath.Dir(exec)
 }
 
+// ConfPath overrides the conf next to the executable, a file is read alone and the conf files of a dir are merged.
+var ConfPath string
+
+// ConfFormat overrides the format of every conf file, which is by the suffix if empty.
+var ConfFormat string
+
 type ConfFileReaderFunc = func() (io.ReadCloser, error)
 
 var ConfFileReader = func(path string) (io.ReadCloser, error) {
@@ -33,5 +43,72 @@ var ConfFileReader = func(path string) (io.ReadCloser, error) {
 }
 
 func ConfFileFileReader() (io.ReadCloser, error) {
-	return ConfFileReader(filepath.Join(ConfReadOption.WorkingPath, ConfReadOption.FilePath, ConfReadOption.Filename+ConfReadOption.FileSuffix))
+	file := ConfFile()
+	if len(file) == 0 {
+		return nil, &os.PathError{Op: "open", Path: ConfPath, Err: os.ErrNotExist}
+	}
+	return ConfFileReader(file)
+}
+
+// ConfFile returns the conf file, empty if the ConfPath is a dir.
+func ConfFile() string {
+	file, _ := confPaths()
+	return file
+}
+
+// confPaths returns the conf file and the conf dir, either is empty if the ConfPath is the other.
+func confPaths() (string, string) {
+	if len(ConfPath) == 0 {
+		name := filepath.Join(ConfReadOption.WorkingPath, ConfReadOption.FilePath, ConfReadOption.Filename)
+		file := name + ConfReadOption.FileSuffixes[0]
+		for _, suffix := range ConfReadOption.FileSuffixes {
+			if _, err := os.Stat(name + suffix); err == nil {
+				file = name + suffix
+				break
+			}
+		}
+
+		return file, filepath.Join(ConfReadOption.WorkingPath, ConfReadOption.FilePath, ConfReadOption.DirPath)
+	}
+
+	if info, err := os.Stat(ConfPath); err == nil && info.IsDir() {
+		return "", ConfPath
+	}
+	return ConfPath, ""
+}
+
+// ConfDirFiles returns the files in the conf.d next to the conf file in lexical order, none if the dir does not exist.
+func ConfDirFiles() ([]string, error) {
+	_, dir := confPaths()
+	if len(dir) == 0 {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(dir, entry.Name()))
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func isConfFile(name string) bool {
+	for _, suffix := range ConfReadOption.FileSuffixes {
+		if filepath.Ext(name) == suffix {
+			return true
+		}
+	}
+	return false
 }
@@ -1,11 +1,73 @@
 package cmd
 
 import (
-	"v2ray.com/core/common/setting/conf"
+	"flag"
+	"fmt"
+	"io"
+	"os"
 )
 
-func init() {
-	if err := conf.Loads(); err != nil {
-		panic(err)
+// Command is a subcommand like "v2ray run -c conf.json".
+type Command struct {
+	Name  string
+	Usage string
+	Run   func(args []string) error
+}
+
+var (
+	commands = []Command{
+		runCommand,
+		testCommand,
+		versionCommand,
+		uuidCommand,
+		certCommand,
+	}
+)
+
+// Execute runs the subcommand of the args without the executable name, it returns the exit code.
+func Execute(args []string) int {
+	if len(args) == 0 {
+		usage(os.Stderr)
+		return 2
+	}
+
+	for _, command := range commands {
+		if command.Name != args[0] {
+			continue
+		}
+
+		if err := command.Run(args[1:]); err != nil {
+			if err == flag.ErrHelp {
+				return 0
+			}
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
 	}
+
+	switch args[0] {
+	case "help", "-h", "-help", "--help":
+		usage(os.Stdout)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %s\n", args[0])
+		usage(os.Stderr)
+		return 2
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: v2ray <command> [arguments]")
+	fmt.Fprintln(w)
+	for _, command := range commands {
+		fmt.Fprintf(w, "  %-8s %s\n", command.Name, command.Usage)
+	}
+}
+
+// newFlagSet returns the flags of the command, the errors are returned rather than exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(os.Stderr)
+	return flags
 }
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	certCommand = Command{
+		Name:  "cert",
+		Usage: "generate a self-signed tls certificate and key, -domain is comma separated",
+		Run:   generateCert,
+	}
+)
+
+func generateCert(args []string) error {
+	flags := newFlagSet("cert")
+	domains := flags.String("domain", "", "comma separated domains or ips of the certificate")
+	expire := flags.Duration("expire", 365*24*time.Hour, "validity of the certificate")
+	certFile := flags.String("cert", "", "certificate file, the stdout if empty")
+	keyFile := flags.String("key", "", "key file, the stdout if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if len(*domains) == 0 {
+		return newError("no domain")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return newError("failed to generate key").WithError(err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return newError("failed to generate serial number").WithError(err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		NotBefore:             now,
+		NotAfter:              now.Add(*expire),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, domain := range strings.Split(*domains, ",") {
+		domain = strings.TrimSpace(domain)
+		if ip := net.ParseIP(domain); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if len(domain) > 0 {
+			template.DNSNames = append(template.DNSNames, domain)
+		}
+	}
+	template.Subject = pkix.Name{
+		CommonName: strings.TrimSpace(strings.Split(*domains, ",")[0]),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return newError("failed to create certificate").WithError(err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return newError("failed to marshal key").WithError(err)
+	}
+
+	if err := writePEM(*certFile, "CERTIFICATE", certDER, 0o644); err != nil {
+		return err
+	}
+	return writePEM(*keyFile, "PRIVATE KEY", keyDER, 0o600)
+}
+
+func writePEM(path string, typ string, der []byte, perm os.FileMode) error {
+	b := pem.EncodeToMemory(&pem.Block{
+		Type:  typ,
+		Bytes: der,
+	})
+
+	if len(path) == 0 {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+
+	if err := os.WriteFile(path, b, perm); err != nil {
+		return newError("failed to write %s", path).WithError(err)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"v2ray.com/core/assets"
+	"v2ray.com/core/common/setting/conf"
+	"v2ray.com/core/common/setting/loader"
+)
+
+var (
+	runCommand = Command{
+		Name:  "run",
+		Usage: "run with the conf, -c is a conf file or a dir of conf files",
+		Run:   run,
+	}
+	testCommand = Command{
+		Name:  "test",
+		Usage: "test the conf and exit, -c is a conf file or a dir of conf files",
+		Run:   test,
+	}
+)
+
+func parseConfFlags(name string, args []string) error {
+	flags := newFlagSet(name)
+	flags.StringVar(&assets.ConfPath, "c", "", "conf file or dir, conf/conf.json and conf/conf.d next to the executable if empty")
+	flags.StringVar(&assets.ConfFormat, "format", "", "json, yaml or toml of every conf file, by the suffix of each file if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := conf.ParseFormat(assets.ConfFormat); err != nil {
+		return err
+	}
+	return nil
+}
+
+func run(args []string) error {
+	if err := parseConfFlags("run", args); err != nil {
+		return err
+	}
+
+	if err := conf.Loads(); err != nil {
+		return newError("failed to load conf").WithError(err)
+	}
+	newError("v2ray %s started", Version).AtInfo().Logging()
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range c {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		// a failed reload changes nothing
+		if err := conf.Reload(); err != nil {
+			newError("failed to reload conf").WithError(err).AtError().Logging()
+		}
+	}
+
+	newError("v2ray %s closing", Version).AtInfo().Logging()
+
+	return loader.RequireInstance().Close()
+}
+
+func test(args []string) error {
+	if err := parseConfFlags("test", args); err != nil {
+		return err
+	}
+
+	problems, err := conf.Check()
+	if err != nil {
+		return newError("invalid conf").WithError(err)
+	}
+
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if err := problems.Err(); err != nil {
+		return newError("invalid conf, %d problems", len(problems))
+	}
+
+	fmt.Println("conf ok")
+	return nil
+}
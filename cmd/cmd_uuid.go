@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"fmt"
+
+	"v2ray.com/core/common/uuid"
+)
+
+var (
+	uuidCommand = Command{
+		Name:  "uuid",
+		Usage: "generate a random uuid for a vmess user",
+		Run:   generateUUID,
+	}
+)
+
+func generateUUID(args []string) error {
+	u, err := uuid.New()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(u.String())
+	return nil
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+var (
+	// Version is set by the build, like -ldflags "-X v2ray.com/core/cmd.Version=1.0.0".
+	Version = "dev"
+
+	versionCommand = Command{
+		Name:  "version",
+		Usage: "print the version",
+		Run:   version,
+	}
+)
+
+func version(args []string) error {
+	revision := ""
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = " " + setting.Value
+			}
+		}
+	}
+
+	fmt.Printf("v2ray %s%s (%s %s/%s)\n", Version, revision, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return nil
+}
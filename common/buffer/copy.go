@@ -1,8 +1,12 @@
 package buffer
 
+import (
+	"os"
+)
+
 var (
-	// ErrReadTimeout is an error that happens with IO timeout.
-	ErrReadTimeout = newError("Buffer Read timeout")
+	// ErrReadTimeout is an error that happens with IO timeout, errors.IsTimeout reports it.
+	ErrReadTimeout = newError("Buffer Read timeout").WithError(os.ErrDeadlineExceeded)
 )
 
 type dataHandler func(MultiBuffer)
@@ -40,6 +44,10 @@ func (e readError) Inner() error {
 	return e.error
 }
 
+func (e readError) Unwrap() error {
+	return e.error
+}
+
 // IsReadError returns true if the error in Copy() comes from reading.
 func IsReadError(err error) bool {
 	_, ok := err.(readError)
@@ -63,6 +71,10 @@ func (e writeError) Inner() error {
 	return e.error
 }
 
+func (e writeError) Unwrap() error {
+	return e.error
+}
+
 // IsWriteError returns true if the error in Copy() comes from writing.
 func IsWriteError(err error) bool {
 	_, ok := err.(writeError)
@@ -133,7 +133,7 @@ func ReadMultiFrom(reader io.Reader) (MultiBuffer, error) {
 			mb = append(mb, b)
 		}
 		if err != nil {
-			if errors.Cause(err) == io.EOF || errors.Cause(err) == io.ErrUnexpectedEOF {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
 				return mb, nil
 			}
 			return mb, err
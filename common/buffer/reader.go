@@ -134,7 +134,7 @@ func (r *bufferedReader) writeToInternal(writer io.Writer) (int64, error) {
 	}
 
 	err := Copy(mbWriter, r.reader, CountSize(&sc))
-	if IsReadError(err) && CauseReadError(err) == io.EOF {
+	if IsReadError(err) && errors.Is(err, io.EOF) {
 		err = nil
 	}
 	return sc.Size, err
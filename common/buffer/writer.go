@@ -263,7 +263,7 @@ func (noOpWriter) ReadFrom(reader io.Reader) (int64, error) {
 		_, err := b.ReadFrom(reader)
 		totalBytes += int64(b.Len())
 		if err != nil {
-			if errors.Cause(err) == io.EOF {
+			if errors.Is(err, io.EOF) {
 				return totalBytes, nil
 			}
 			return totalBytes, err
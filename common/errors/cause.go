@@ -9,7 +9,7 @@ type hasInnerError interface {
 	Inner() error
 }
 
-// Cause returns the root cause of this error.
+// Cause returns the root cause of this error, the first cause is followed if there are several.
 func Cause(err error) error {
 	if err == nil {
 		return nil
@@ -32,6 +32,17 @@ L:
 				break L
 			}
 			err = inner.Err
+		case interface{ Unwrap() error }:
+			if inner.Unwrap() == nil {
+				break L
+			}
+			err = inner.Unwrap()
+		case interface{ Unwrap() []error }:
+			errs := inner.Unwrap()
+			if len(errs) == 0 || errs[0] == nil {
+				break L
+			}
+			err = errs[0]
 		default:
 			break L
 		}
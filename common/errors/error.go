@@ -1,36 +1,132 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
 
 	"v2ray.com/core/common/log"
 )
 
+// Error is a message with its path, level and session, and the causes appended by WithError.
 type Error struct {
-	level log.Level
-	path  string
-	error error
+	level   log.Level
+	path    string
+	session log.Session
+	msg     string
+	causes  *causeList
+}
+
+// causeList is an immutable list of the causes, the newest first, so that an Error is still comparable.
+type causeList struct {
+	err  error
+	next *causeList
+}
+
+// Session is the session an error belongs to, like a session.Content.
+type Session interface {
+	LogSession() log.Session
 }
 
 func New(msg string, args ...interface{}) Error {
 	return Error{
-		error: fmt.Errorf(msg, args...),
+		msg: fmt.Sprintf(msg, args...),
 	}
 }
 
+// Error is like "path: msg > cause > cause".
 func (e Error) Error() string {
-	return e.error.Error()
+	var b strings.Builder
+
+	if len(e.path) > 0 {
+		b.WriteString(e.path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.msg)
+
+	for _, err := range e.Unwrap() {
+		b.WriteString(" > ")
+		b.WriteString(err.Error())
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the causes in the order appended, so that errors.Is and errors.As walk them.
+func (e Error) Unwrap() []error {
+	var errs []error
+	for c := e.causes; c != nil; c = c.next {
+		errs = append(errs, c.err)
+	}
+
+	for i, j := 0, len(errs)-1; i < j; i, j = i+1, j-1 {
+		errs[i], errs[j] = errs[j], errs[i]
+	}
+
+	return errs
 }
 
+// Is reports whether a cause matches the target, errors.Is follows the Unwrap() []error only since go 1.20.
+func (e Error) Is(target error) bool {
+	for _, err := range e.Unwrap() {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first cause that matches the target, errors.As follows the Unwrap() []error only since go 1.20.
+func (e Error) As(target interface{}) bool {
+	for _, err := range e.Unwrap() {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Error) Path() string {
+	return e.path
+}
+
+func (e Error) Level() log.Level {
+	return e.level
+}
+
+// WithError appends the err as a cause, the session of the err is kept if the error has none.
 func (e Error) WithError(err interface{}) Error {
-	e.error = fmt.Errorf("%v > %v", e.error, err)
+	if err == nil {
+		return e
+	}
+
+	if inner, ok := err.(Error); ok && e.session.ID == 0 {
+		e.session = inner.session
+	}
+
+	cause, ok := err.(error)
+	if !ok {
+		cause = fmt.Errorf("%v", err)
+	}
+
+	e.causes = &causeList{
+		err:  cause,
+		next: e.causes,
+	}
+	return e
+}
+
+// WithSession tags the logged line by the session.
+func (e Error) WithSession(session Session) Error {
+	if session != nil {
+		e.session = session.LogSession()
+	}
 	return e
 }
 
 func (e Error) WithPath(path interface{}) Error {
 	e.path = e.resolvePath(path)
-	e.error = fmt.Errorf("%s: %v", e.path, e.error)
 	return e
 }
 
@@ -64,22 +160,16 @@ func (e Error) AtNone() Error {
 	return e.atLevel(log.None)
 }
 
+// AtLevel sets the level, like the level of the cause by Level.
+func (e Error) AtLevel(level log.Level) Error {
+	return e.atLevel(level)
+}
+
 func (e Error) atLevel(level log.Level) Error {
 	e.level = level
 	return e
 }
 
 func (e Error) Logging() {
-	switch e.level {
-	case log.Debug:
-		log.Debugf(e.error.Error())
-	case log.Info:
-		log.Infof(e.error.Error())
-	case log.Warning:
-		log.Warnf(e.error.Error())
-	case log.Error:
-		log.Errorf(e.error.Error())
-	case log.None:
-	default:
-	}
+	log.Log(e.level, e.session, e.Error())
 }
@@ -1 +1,56 @@
 package errors
+
+import (
+	"errors"
+	"io"
+	"os"
+	"syscall"
+
+	"v2ray.com/core/common/log"
+)
+
+// ErrAuth is a cause of the errors of an unknown user or a wrong password.
+var ErrAuth = errors.New("authentication failed")
+
+// Is reports whether any error in the causes of err matches the target, like the errors.Is of the standard library.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// As finds the first error in the causes of err that matches the target, like the errors.As of the standard library.
+func As(err error, target interface{}) bool {
+	return errors.As(err, target)
+}
+
+// IsTimeout reports whether a cause of err is a timeout, like a deadline of a net.Conn.
+func IsTimeout(err error) bool {
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// IsReset reports whether a cause of err is a connection reset or aborted by the peer.
+func IsReset(err error) bool {
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE)
+}
+
+// IsAuth reports whether a cause of err is ErrAuth.
+func IsAuth(err error) bool {
+	return errors.Is(err, ErrAuth)
+}
+
+// Level returns the level to log err at, the timeouts, the resets and the early eofs of the peers are usual and at debug,
+// the failed authentications are at warning and the others are at info.
+func Level(err error) log.Level {
+	switch {
+	case IsAuth(err):
+		return log.Warning
+	case IsTimeout(err), IsReset(err), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return log.Debug
+	default:
+		return log.Info
+	}
+}
@@ -4,16 +4,22 @@ var (
 	defaultLevel  = Debug
 	defaultPrefix = NewPrefix("v2ray")
 
-	localLogger = NewSimpleLogger(defaultLevel, defaultPrefix)
+	localLogger = newRegisteredLogger(NewSimpleLogger(defaultLevel, defaultPrefix))
 
-	Close = localLogger.Close
+	Close    = localLogger.Close
+	Reopen   = localLogger.Reopen
+	SetLevel = localLogger.SetLevel
+	GetLevel = localLogger.Level
 
 	Debugf = localLogger.Debugf
 	Infof  = localLogger.Infof
 	Warnf  = localLogger.Warningf
 	Errorf = localLogger.Errorf
+
+	Log = localLogger.Log
 )
 
+// RegisterAlternativeLogger replaces the logger, the replaced one is not closed.
 func RegisterAlternativeLogger(logger Logger) {
-	localLogger = logger
+	localLogger.register(logger)
 }
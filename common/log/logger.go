@@ -1,10 +1,152 @@
 package log
 
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
 type Logger interface {
 	Close() error
+	// Reopen opens the file written again, it does nothing for the stdout.
+	Reopen() error
+
+	SetLevel(Level)
+	Level() Level
 
 	Debugf(msg string, args ...interface{})
 	Infof(msg string, args ...interface{})
 	Warningf(msg string, args ...interface{})
 	Errorf(msg string, args ...interface{})
+
+	// Log writes the message of the session at the level.
+	Log(level Level, session Session, msg string)
+}
+
+// Session identifies the connection a message belongs to, a zero ID is none.
+type Session struct {
+	ID      uint32
+	Inbound string
+}
+
+// String is like "[3735928559 socks] ", empty if none.
+func (s Session) String() string {
+	if s.ID == 0 {
+		return ""
+	}
+	return fmt.Sprintf("[%d %s] ", s.ID, s.Inbound)
+}
+
+type Reopener interface {
+	Reopen() error
+}
+
+// Format is the line format of a logger.
+type Format byte
+
+const (
+	Text Format = iota
+	JSON
+)
+
+type Setting struct {
+	Level  Level
+	Format Format
+	// Path is the file appended to, the stdout is written if empty.
+	Path     string
+	Rotation Rotation
+}
+
+func NewLogger(setting Setting) (Logger, error) {
+	var writer io.WriteCloser = nopCloser{os.Stdout}
+	if len(setting.Path) > 0 {
+		file, err := NewFileWriter(setting.Path, setting.Rotation)
+		if err != nil {
+			return nil, err
+		}
+		writer = file
+	}
+
+	switch setting.Format {
+	case JSON:
+		return NewJSONLogger(setting.Level, writer), nil
+	default:
+		return NewWriterLogger(setting.Level, defaultPrefix, writer), nil
+	}
+}
+
+func reopen(writer io.Writer) error {
+	if reopener, ok := writer.(Reopener); ok {
+		return reopener.Reopen()
+	}
+	return nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// registeredLogger forwards to the registered logger, so that it is replaced at runtime.
+type registeredLogger struct {
+	logger atomic.Value
+}
+
+// loggerBox keeps the type stored in the atomic value the same.
+type loggerBox struct {
+	Logger
+}
+
+func newRegisteredLogger(logger Logger) *registeredLogger {
+	l := &registeredLogger{}
+	l.register(logger)
+	return l
+}
+
+func (l *registeredLogger) register(logger Logger) {
+	l.logger.Store(loggerBox{logger})
+}
+
+func (l *registeredLogger) load() Logger {
+	return l.logger.Load().(loggerBox).Logger
+}
+
+func (l *registeredLogger) Close() error {
+	return l.load().Close()
+}
+
+func (l *registeredLogger) Reopen() error {
+	return l.load().Reopen()
+}
+
+func (l *registeredLogger) SetLevel(level Level) {
+	l.load().SetLevel(level)
+}
+
+func (l *registeredLogger) Level() Level {
+	return l.load().Level()
+}
+
+func (l *registeredLogger) Debugf(msg string, args ...interface{}) {
+	l.load().Debugf(msg, args...)
+}
+
+func (l *registeredLogger) Infof(msg string, args ...interface{}) {
+	l.load().Infof(msg, args...)
+}
+
+func (l *registeredLogger) Warningf(msg string, args ...interface{}) {
+	l.load().Warningf(msg, args...)
+}
+
+func (l *registeredLogger) Errorf(msg string, args ...interface{}) {
+	l.load().Errorf(msg, args...)
+}
+
+func (l *registeredLogger) Log(level Level, session Session, msg string) {
+	l.load().Log(level, session, msg)
 }
@@ -41,6 +41,10 @@ func (l *loggerHandler) Close() error {
 	return nil
 }
 
+func (l *loggerHandler) Reopen() error {
+	return reopen(l.Writer())
+}
+
 func StdGoLoggerWithTimestamp(flag int) *log.Logger {
 	return StdGoLogger(flag | log.Ldate | log.Ltime)
 }
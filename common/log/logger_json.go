@@ -0,0 +1,103 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// jsonLogger writes a json object per line, like
+// {"time":"2006-01-02T15:04:05.999999999Z","level":"info","session":3735928559,"inbound":"socks","msg":"..."}
+type jsonLogger struct {
+	level atomic.Uint32
+
+	sync.Mutex
+	writer io.WriteCloser
+}
+
+func NewJSONLogger(level Level, writer io.WriteCloser) Logger {
+	l := &jsonLogger{
+		writer: writer,
+	}
+	l.SetLevel(level)
+	return l
+}
+
+type jsonEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Session uint32    `json:"session,omitempty"`
+	Inbound string    `json:"inbound,omitempty"`
+	Msg     string    `json:"msg"`
+}
+
+func (l *jsonLogger) Close() error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.writer.Close()
+}
+
+func (l *jsonLogger) Reopen() error {
+	l.Lock()
+	defer l.Unlock()
+
+	return reopen(l.writer)
+}
+
+func (l *jsonLogger) SetLevel(level Level) {
+	l.level.Store(uint32(level))
+}
+
+func (l *jsonLogger) Level() Level {
+	return Level(l.level.Load())
+}
+
+func (l *jsonLogger) write(level Level, msg string, args ...interface{}) {
+	if l.Level() > level {
+		return
+	}
+
+	l.Log(level, Session{}, fmt.Sprintf(msg, args...))
+}
+
+func (l *jsonLogger) Log(level Level, session Session, msg string) {
+	if level >= None || l.Level() > level {
+		return
+	}
+
+	b, err := json.Marshal(jsonEntry{
+		Time:    time.Now(),
+		Level:   level.String(),
+		Session: session.ID,
+		Inbound: session.Inbound,
+		Msg:     msg,
+	})
+	if err != nil {
+		return
+	}
+
+	l.Lock()
+	defer l.Unlock()
+
+	_, _ = l.writer.Write(append(b, '\n'))
+}
+
+func (l *jsonLogger) Debugf(msg string, args ...interface{}) {
+	l.write(Debug, msg, args...)
+}
+
+func (l *jsonLogger) Infof(msg string, args ...interface{}) {
+	l.write(Info, msg, args...)
+}
+
+func (l *jsonLogger) Warningf(msg string, args ...interface{}) {
+	l.write(Warning, msg, args...)
+}
+
+func (l *jsonLogger) Errorf(msg string, args ...interface{}) {
+	l.write(Error, msg, args...)
+}
@@ -1,43 +1,93 @@
 package log
 
+import (
+	"io"
+	"log"
+	"sync/atomic"
+)
+
+var levelPrefixes = map[Level]string{
+	Debug:   "[Debug] ",
+	Info:    "[Info] ",
+	Warning: "[Warning] ",
+	Error:   "[Error] ",
+}
+
 type simpleLogger struct {
 	logger LoggerHandler
-	level  Level
+	level  atomic.Uint32
 	prefix Prefix
 }
 
 func NewSimpleLogger(level Level, prefix Prefix) Logger {
-	return &simpleLogger{
+	l := &simpleLogger{
 		logger: NewSimpleLoggerHandler(StdGoLoggerWithTimestamp(0)),
-		level:  level,
 		prefix: prefix,
 	}
+	l.SetLevel(level)
+
+	return l
+}
+
+// NewWriterLogger writes the text lines to the writer.
+func NewWriterLogger(level Level, prefix Prefix, writer io.WriteCloser) Logger {
+	l := &simpleLogger{
+		logger: NewSimpleLoggerHandler(OutGoLogger(writer, prefix_empty, log.Ldate|log.Ltime)),
+		prefix: prefix,
+	}
+	l.SetLevel(level)
+	return l
 }
 
 func (l *simpleLogger) Close() error {
 	return l.logger.Close()
 }
 
+func (l *simpleLogger) Reopen() error {
+	if reopener, ok := l.logger.(Reopener); ok {
+		return reopener.Reopen()
+	}
+	return nil
+}
+
+func (l *simpleLogger) SetLevel(level Level) {
+	l.level.Store(uint32(level))
+}
+
+func (l *simpleLogger) Level() Level {
+	return Level(l.level.Load())
+}
+
+func (l *simpleLogger) enabled(level Level) bool {
+	return l.Level() <= level
+}
+
 func (l *simpleLogger) Debugf(msg string, args ...interface{}) {
-	if l.level <= Debug {
+	if l.enabled(Debug) {
 		l.logger.Printf("[Debug] "+msg, args...)
 	}
 }
 
 func (l *simpleLogger) Infof(msg string, args ...interface{}) {
-	if l.level <= Info {
+	if l.enabled(Info) {
 		l.logger.Printf("[Info] "+msg, args...)
 	}
 }
 
 func (l *simpleLogger) Warningf(msg string, args ...interface{}) {
-	if l.level <= Warning {
+	if l.enabled(Warning) {
 		l.logger.Printf("[Warning] "+msg, args...)
 	}
 }
 
 func (l *simpleLogger) Errorf(msg string, args ...interface{}) {
-	if l.level <= Error {
+	if l.enabled(Error) {
 		l.logger.Printf("[Error] "+msg, args...)
 	}
 }
+
+func (l *simpleLogger) Log(level Level, session Session, msg string) {
+	if level < None && l.enabled(level) {
+		l.logger.Printf("%s%s%s", levelPrefixes[level], session, msg)
+	}
+}
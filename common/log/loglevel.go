@@ -2,6 +2,7 @@ package log
 
 import (
 	"fmt"
+	"strings"
 )
 
 type Level byte
@@ -14,6 +15,31 @@ const (
 	None
 )
 
+var levelNames = map[Level]string{
+	Debug:   "debug",
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+	None:    "none",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// ParseLevel parses a level like "debug", it is case insensitive.
+func ParseLevel(s string) (Level, bool) {
+	for level, name := range levelNames {
+		if name == strings.ToLower(s) {
+			return level, true
+		}
+	}
+	return None, false
+}
+
 type Prefix = string
 
 func NewPrefix(tag Prefix) Prefix {
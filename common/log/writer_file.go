@@ -0,0 +1,242 @@
+package log
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rotateTimeFormat = "20060102-150405"
+
+	// rotateRetry is the wait after a failed rotation, the file is still written meanwhile.
+	rotateRetry = time.Minute
+)
+
+// Rotation rotates a file once it is too large or too old, a zero value never rotates.
+type Rotation struct {
+	MaxSize    int64
+	MaxAge     time.Duration
+	MaxBackups int
+}
+
+// FileWriter appends to a file, it is renamed to "path.20060102-150405" once rotated,
+// or "path.20060102-150405.1" and so on if rotated again in the same second.
+// Reopen opens the path again, as logrotate moves the file.
+type FileWriter struct {
+	path     string
+	rotation Rotation
+
+	sync.Mutex
+	file    *os.File
+	size    int64
+	created time.Time
+	// retryAt delays the rotation after a failed one
+	retryAt time.Time
+}
+
+func NewFileWriter(path string, rotation Rotation) (*FileWriter, error) {
+	w := &FileWriter{
+		path:     path,
+		rotation: rotation,
+	}
+
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *FileWriter) open() error {
+	file, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+
+	w.file = file
+	w.size = info.Size()
+	w.created = w.createdTime()
+
+	return nil
+}
+
+// createdTime is when the file was created, which is when the newest backup was rotated. The creation time
+// is not in the file info, so an existing file without any backup is as if created now.
+func (w *FileWriter) createdTime() time.Time {
+	now := time.Now()
+	if w.size == 0 {
+		return now
+	}
+
+	backups := w.backups()
+	if len(backups) == 0 {
+		return now
+	}
+
+	if t := backups[len(backups)-1].time; t.Before(now) {
+		return t
+	}
+	return now
+}
+
+func (w *FileWriter) Write(b []byte) (int, error) {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return 0, os.ErrClosed
+	}
+
+	if w.expired(int64(len(b))) {
+		if err := w.rotate(); err != nil {
+			if w.file == nil {
+				return 0, err
+			}
+			// the logger has no way to report it, the file is still written
+			fmt.Fprintf(os.Stderr, "failed to rotate log %s: %v\n", w.path, err)
+		}
+	}
+
+	n, err := w.file.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *FileWriter) expired(n int64) bool {
+	if w.size == 0 || time.Now().Before(w.retryAt) {
+		return false
+	}
+	if w.rotation.MaxSize > 0 && w.size+n > w.rotation.MaxSize {
+		return true
+	}
+	if w.rotation.MaxAge > 0 && time.Since(w.created) > w.rotation.MaxAge {
+		return true
+	}
+	return false
+}
+
+// rotate renames the file to a new backup and opens the path again, the path is opened again even if the renaming fails.
+func (w *FileWriter) rotate() error {
+	_ = w.file.Close()
+	w.file = nil
+
+	if err := os.Rename(w.path, w.backupName(time.Now())); err != nil {
+		w.retryAt = time.Now().Add(rotateRetry)
+		if err2 := w.open(); err2 != nil {
+			return err2
+		}
+		return err
+	}
+	w.removeBackups()
+
+	return w.open()
+}
+
+// backupName is "path.20060102-150405", with a ".1" and so on if it exists.
+func (w *FileWriter) backupName(t time.Time) string {
+	name := w.path + "." + t.Format(rotateTimeFormat)
+	for i := 1; ; i++ {
+		if _, err := os.Lstat(name); os.IsNotExist(err) {
+			return name
+		}
+		name = w.path + "." + t.Format(rotateTimeFormat) + "." + strconv.Itoa(i)
+	}
+}
+
+type backup struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+// backups returns the rotated files, the oldest first.
+func (w *FileWriter) backups() []backup {
+	names, err := filepath.Glob(w.path + ".*")
+	if err != nil {
+		return nil
+	}
+
+	var backups []backup
+	for _, name := range names {
+		suffix := strings.TrimPrefix(name, w.path+".")
+
+		seq := 0
+		if i := strings.IndexByte(suffix, '.'); i >= 0 {
+			n, err := strconv.Atoi(suffix[i+1:])
+			if err != nil {
+				continue
+			}
+			suffix, seq = suffix[:i], n
+		}
+
+		t, err := time.ParseInLocation(rotateTimeFormat, suffix, time.Local)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, backup{
+			name: name,
+			time: t,
+			seq:  seq,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+	return backups
+}
+
+// removeBackups removes the oldest rotated files beyond MaxBackups.
+func (w *FileWriter) removeBackups() {
+	if w.rotation.MaxBackups <= 0 {
+		return
+	}
+
+	backups := w.backups()
+	if len(backups) <= w.rotation.MaxBackups {
+		return
+	}
+
+	for _, backup := range backups[:len(backups)-w.rotation.MaxBackups] {
+		_ = os.Remove(backup.name)
+	}
+}
+
+func (w *FileWriter) Reopen() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+func (w *FileWriter) Close() error {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
@@ -0,0 +1,31 @@
+package process
+
+import (
+	"strconv"
+
+	"v2ray.com/core/common/net"
+)
+
+var (
+	errNotFound     = newError("process not found")
+	errNotSupported = newError("not supported")
+)
+
+// Info is the owner of a local socket.
+type Info struct {
+	UID  uint32
+	PID  int
+	Name string
+	Path string
+}
+
+func (i Info) UIDString() string {
+	return strconv.FormatUint(uint64(i.UID), 10)
+}
+
+// FindProcess returns the owner of the local socket bound to the source address.
+func FindProcess(source net.Address) (Info, error) {
+	return findProcess(source)
+}
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
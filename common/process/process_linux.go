@@ -0,0 +1,251 @@
+//go:build linux
+
+package process
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+
+	"v2ray.com/core/common/net"
+)
+
+const (
+	procRoot = "/proc"
+	// socketPIDsTTL is how long the pids found by a walk of /proc are trusted.
+	socketPIDsTTL = 2 * time.Second
+)
+
+// nativeEndian is the byte order of the host.
+var nativeEndian binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+// socketPIDs caches the pids of all the socket inodes found by the last walk of /proc.
+var socketPIDs struct {
+	sync.Mutex
+	pids   map[string]int
+	walked time.Time
+}
+
+// https://www.kernel.org/doc/Documentation/networking/proc_net_tcp.txt
+const (
+	fieldLocalAddress = 1
+	fieldUID          = 7
+	fieldInode        = 9
+)
+
+func findProcess(source net.Address) (Info, error) {
+	uid, inode, err := findSocket(source)
+	if err != nil {
+		return Info{}, err
+	}
+
+	pid, err := findPID(inode)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{
+		UID: uid,
+		PID: pid,
+	}
+
+	if comm, err := os.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "comm")); err == nil {
+		info.Name = strings.TrimSpace(string(comm))
+	}
+
+	if exe, err := os.Readlink(filepath.Join(procRoot, strconv.Itoa(pid), "exe")); err == nil {
+		info.Path = exe
+	}
+
+	return info, nil
+}
+
+// findSocket returns the uid and inode of the socket whose local address is the source.
+func findSocket(source net.Address) (uint32, string, error) {
+	files := func() []string {
+		switch source.Network {
+		case net.Network_TCP:
+			return []string{"net/tcp", "net/tcp6"}
+		case net.Network_UDP:
+			return []string{"net/udp", "net/udp6"}
+		default:
+			return nil
+		}
+	}()
+
+	for _, name := range files {
+		uid, inode, err := findSocketInFile(filepath.Join(procRoot, name), source)
+		if err == nil {
+			return uid, inode, nil
+		}
+	}
+
+	return 0, "", errNotFound
+}
+
+func findSocketInFile(name string, source net.Address) (uint32, string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// an unconnected udp socket is only bound to its port
+	var anyUID uint32
+	var anyInode string
+
+	scanner := bufio.NewScanner(file)
+	scanner.Scan() // skip header
+
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) <= fieldInode {
+			continue
+		}
+
+		ip, port, err := parseSocketAddress(fields[fieldLocalAddress])
+		if err != nil || port != source.Port {
+			continue
+		}
+
+		uid, err := strconv.ParseUint(fields[fieldUID], 10, 32)
+		if err != nil {
+			continue
+		}
+
+		inode := fields[fieldInode]
+		if inode == "0" {
+			continue
+		}
+
+		if ip.Equal(source.IP) {
+			return uint32(uid), inode, nil
+		}
+
+		if source.Network == net.Network_UDP && ip.IsUnspecified() && len(anyInode) == 0 {
+			anyUID, anyInode = uint32(uid), inode
+		}
+	}
+
+	if len(anyInode) > 0 {
+		return anyUID, anyInode, nil
+	}
+
+	return 0, "", errNotFound
+}
+
+// parseSocketAddress parses "0100007F:1F90" into 127.0.0.1 and 8080.
+// the ip is stored as 32-bit words in host byte order.
+func parseSocketAddress(s string) (net.IP, net.Port, error) {
+	host, portStr, ok := strings.Cut(s, ":")
+	if !ok {
+		return nil, 0, newError("invalid socket address %s", s)
+	}
+
+	b, err := hex.DecodeString(host)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(b) != net.IPv4len && len(b) != net.IPv6len {
+		return nil, 0, newError("invalid socket address %s", s)
+	}
+
+	for i := 0; i < len(b); i += 4 {
+		binary.BigEndian.PutUint32(b[i:], nativeEndian.Uint32(b[i:]))
+	}
+
+	port, err := strconv.ParseUint(portStr, 16, 16)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return net.IP(b), net.Port(port), nil
+}
+
+// findPID returns the pid of the process holding a descriptor of the socket inode.
+// /proc is walked again only if the inode is not found by a walk started after the call.
+func findPID(inode string) (int, error) {
+	start := time.Now()
+
+	socketPIDs.Lock()
+	defer socketPIDs.Unlock()
+
+	if pid, ok := socketPIDs.pids[inode]; ok && time.Since(socketPIDs.walked) < socketPIDsTTL {
+		return pid, nil
+	}
+
+	// a walk started after the call has seen the socket if it exists
+	if socketPIDs.walked.Before(start) {
+		walked := time.Now()
+
+		pids, err := walkSocketPIDs()
+		if err != nil {
+			return 0, err
+		}
+
+		socketPIDs.pids, socketPIDs.walked = pids, walked
+	}
+
+	if pid, ok := socketPIDs.pids[inode]; ok {
+		return pid, nil
+	}
+
+	return 0, errNotFound
+}
+
+// walkSocketPIDs returns the pids of the socket inodes held by all processes.
+func walkSocketPIDs() (map[string]int, error) {
+	procs, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	pids := make(map[string]int)
+
+	for _, proc := range procs {
+		pid, err := strconv.Atoi(proc.Name())
+		if err != nil {
+			continue
+		}
+
+		fdPath := filepath.Join(procRoot, proc.Name(), "fd")
+
+		fds, err := os.ReadDir(fdPath)
+		if err != nil {
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdPath, fd.Name()))
+			if err != nil {
+				continue
+			}
+
+			if !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+
+			inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+			if _, ok := pids[inode]; !ok {
+				pids[inode] = pid
+			}
+		}
+	}
+
+	return pids, nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package process
+
+import (
+	"v2ray.com/core/common/net"
+)
+
+func findProcess(_ net.Address) (Info, error) {
+	return Info{}, errNotSupported
+}
@@ -0,0 +1,3 @@
+package bittorrent
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
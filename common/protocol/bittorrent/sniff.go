@@ -0,0 +1,104 @@
+package bittorrent
+
+import (
+	"bytes"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+var (
+	// https://www.bittorrent.org/beps/bep_0003.html#peer-protocol
+	handshakeHeader = append([]byte{19}, "BitTorrent protocol"...)
+
+	// https://www.bittorrent.org/beps/bep_0005.html#krpc-protocol
+	dhtQueryHeader    = []byte("d1:ad2:id20:")
+	dhtResponseHeader = []byte("d1:rd2:id20:")
+
+	errNotBitTorrent = newError("not bittorrent")
+)
+
+// https://www.bittorrent.org/beps/bep_0029.html#header-format
+const (
+	utpHeaderLen = 20
+	utpVersion   = 1
+	utpTypeSyn   = 4
+)
+
+type tcpSniffer struct {
+}
+
+// NewSniffer sniffs the handshake of peer protocol.
+func NewSniffer() sniffer.Sniffer {
+	return &tcpSniffer{}
+}
+
+func (s *tcpSniffer) Protocol() sniffer.Protocol {
+	return sniffer.BitTorrent
+}
+
+func (s *tcpSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if len(b0) < len(handshakeHeader) {
+		if bytes.HasPrefix(handshakeHeader, b0) {
+			return sniffer.SniffResult{}, sniffer.ErrNeedMore
+		}
+		return sniffer.SniffResult{}, errNotBitTorrent
+	}
+
+	if !bytes.HasPrefix(b0, handshakeHeader) {
+		return sniffer.SniffResult{}, errNotBitTorrent
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
+
+type udpSniffer struct {
+}
+
+// NewUDPSniffer sniffs the syn packet of uTP and the messages of DHT.
+func NewUDPSniffer() sniffer.Sniffer {
+	return &udpSniffer{}
+}
+
+func (s *udpSniffer) Protocol() sniffer.Protocol {
+	return sniffer.BitTorrent
+}
+
+func (s *udpSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if !isUTPSyn(b0) && !isDHTMessage(b0) {
+		return sniffer.SniffResult{}, errNotBitTorrent
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
+
+func isUTPSyn(b []byte) bool {
+	if len(b) < utpHeaderLen {
+		return false
+	}
+
+	// type and version
+	if b[0] != utpTypeSyn<<4|utpVersion {
+		return false
+	}
+
+	// the extension chain is terminated by 0, only the selective ack (1) is defined
+	if b[1] > 1 {
+		return false
+	}
+
+	return true
+}
+
+func isDHTMessage(b []byte) bool {
+	return bytes.HasPrefix(b, dhtQueryHeader) || bytes.HasPrefix(b, dhtResponseHeader)
+}
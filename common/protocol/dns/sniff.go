@@ -0,0 +1,80 @@
+package dns
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+var (
+	errNotDNS = newError("not dns query")
+)
+
+type dnsSniffer struct {
+	network net.Network
+}
+
+// NewSniffer sniffs dns queries on any port, the queries on tcp are prefixed by their length.
+func NewSniffer(network net.Network) sniffer.Sniffer {
+	return &dnsSniffer{
+		network: network,
+	}
+}
+
+func (s *dnsSniffer) Protocol() sniffer.Protocol {
+	return sniffer.DNS
+}
+
+func (s *dnsSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if s.network == net.Network_TCP {
+		if len(b0) < 2 {
+			return sniffer.SniffResult{}, errNotDNS
+		}
+
+		length := int(binary.BigEndian.Uint16(b0[:2]))
+		if 2+length > len(b0) {
+			return sniffer.SniffResult{}, errNotDNS
+		}
+
+		b0 = b0[2 : 2+length]
+	}
+
+	if !isQuery(b0) {
+		return sniffer.SniffResult{}, errNotDNS
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
+
+func isQuery(b []byte) bool {
+	var parser dnsmessage.Parser
+
+	header, err := parser.Start(b)
+	if err != nil {
+		return false
+	}
+
+	if header.Response || header.OpCode != 0 {
+		return false
+	}
+
+	questions, err := parser.AllQuestions()
+	if err != nil || len(questions) == 0 {
+		return false
+	}
+
+	// a query carries no answers
+	if _, err := parser.AnswerHeader(); err != dnsmessage.ErrSectionDone {
+		return false
+	}
+
+	return true
+}
@@ -44,6 +44,7 @@ func (s *httpSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, er
 	return sniffer.SniffResult{
 		Protocol: s.Protocol(),
 		Domain:   domain,
+		Method:   readMethod(b0),
 	}, nil
 }
 
@@ -69,6 +70,13 @@ func readRawHost(b []byte) (string, error) {
 	return "", errNoClue
 }
 
+func readMethod(b []byte) string {
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		return strings.ToUpper(string(b[:i]))
+	}
+	return ""
+}
+
 func beginWithHTTPMethod(b []byte) error {
 	for _, m := range methods {
 		if len(b) >= len(m) && strings.EqualFold(string(b[:len(m)]), m) {
@@ -0,0 +1,125 @@
+package http
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+// https://www.rfc-editor.org/rfc/rfc9113#section-3.4
+const (
+	h2cPreface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+	h2cFrameHeaderLen = 9
+	h2cFrameHeaders   = 0x1
+
+	h2cFlagPadded   = 0x8
+	h2cFlagPriority = 0x20
+
+	h2cAuthority = ":authority"
+	h2cMethod    = ":method"
+)
+
+var (
+	errNotH2C = newError("not h2c preface")
+)
+
+type h2cSniffer struct {
+}
+
+// NewH2CSniffer sniffs the prior knowledge preface of HTTP/2, with the authority of the first headers if any.
+func NewH2CSniffer() sniffer.Sniffer {
+	return &h2cSniffer{}
+}
+
+func (s *h2cSniffer) Protocol() sniffer.Protocol {
+	return sniffer.H2C
+}
+
+func (s *h2cSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if len(b0) < len(h2cPreface) {
+		if strings.HasPrefix(h2cPreface, string(b0)) {
+			return sniffer.SniffResult{}, sniffer.ErrNeedMore
+		}
+		return sniffer.SniffResult{}, errNotH2C
+	}
+
+	if !bytes.HasPrefix(b0, []byte(h2cPreface)) {
+		return sniffer.SniffResult{}, errNotH2C
+	}
+
+	authority, method := readH2CHeaders(b0[len(h2cPreface):])
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+		Domain:   authority,
+		Method:   method,
+	}, nil
+}
+
+// readH2CHeaders returns the authority and method of the first headers frame, or empty.
+func readH2CHeaders(b []byte) (string, string) {
+	for len(b) >= h2cFrameHeaderLen {
+		length := int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		frameType, flags := b[3], b[4]
+
+		b = b[h2cFrameHeaderLen:]
+		if length > len(b) {
+			return "", ""
+		}
+
+		payload := b[:length]
+		b = b[length:]
+
+		if frameType != h2cFrameHeaders {
+			continue
+		}
+
+		if flags&h2cFlagPadded != 0 {
+			if len(payload) < 1 || int(payload[0])+1 > len(payload) {
+				return "", ""
+			}
+			payload = payload[1 : len(payload)-int(payload[0])]
+		}
+
+		if flags&h2cFlagPriority != 0 {
+			if len(payload) < 5 {
+				return "", ""
+			}
+			payload = payload[5:]
+		}
+
+		return decodeH2CHeaders(payload)
+	}
+
+	return "", ""
+}
+
+func decodeH2CHeaders(block []byte) (string, string) {
+	authority, method := "", ""
+
+	decoder := hpack.NewDecoder(4096, func(f hpack.HeaderField) {
+		switch {
+		case f.Name == h2cAuthority && len(authority) == 0:
+			authority = f.Value
+		case f.Name == h2cMethod && len(method) == 0:
+			method = f.Value
+		}
+	})
+
+	if _, err := decoder.Write(block); err != nil && len(authority) == 0 {
+		return "", method
+	}
+
+	if host, _, err := net.SplitHostPort(authority); err == nil {
+		return strings.ToLower(host), method
+	}
+	return strings.ToLower(authority), method
+}
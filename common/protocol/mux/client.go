@@ -6,6 +6,7 @@ import (
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/io"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
@@ -30,6 +31,12 @@ func NewClient(outbound proxyman.Outbound) proxyman.Outbound {
 	}
 }
 
+func (c *client) Close() error {
+	_ = c.sessionsManager.Close()
+
+	return c.Outbound.Close()
+}
+
 func (c *client) Dispatch(content session.Content, address net.Address, link transport.Link) error {
 	transferType := func() protocol.TransferType {
 		ib, _ := content.GetInbound()
@@ -55,7 +62,7 @@ func (c *client) Dispatch(content session.Content, address net.Address, link tra
 
 					return c.Outbound.Dispatch(content, address, outboundLink)
 				}(); err != nil {
-					newError("failed to dispatch").WithError(err).AtDebug().Logging()
+					newError("failed to dispatch").WithError(err).WithSession(content).AtDebug().Logging()
 				}
 			}()
 
@@ -68,7 +75,7 @@ func (c *client) Dispatch(content session.Content, address net.Address, link tra
 
 					return c.handleOutput(manager)
 				}(); err != nil {
-					newError("failed to handle Output").WithError(err).AtDebug().Logging()
+					newError("failed to handle Output").WithError(err).WithSession(content).AtDebug().Logging()
 				}
 			}()
 		}
@@ -109,7 +116,7 @@ func (c *client) handleInput(manager *sessionManager, body sessionBody, meta ses
 
 	if err := func() error {
 		err := buffer.Copy(writer, buffer.NewTimeoutReader(body.link.Reader, timeoutFirstPayload))
-		if buffer.IsReadError(err) && buffer.CauseReadError(err) == buffer.ErrReadTimeout {
+		if errors.Is(err, buffer.ErrReadTimeout) {
 			return writer.WriteMultiBuffer(buffer.MultiBuffer{})
 		}
 		return err
@@ -174,7 +181,7 @@ func (c *client) handleStatusKeep(manager *sessionManager, meta frameMetadata, r
 		rr := NewReader(reader, protocol.TransferTypeFromNetwork(body.target.Network))
 
 		err := buffer.Copy(body.link.Writer, rr)
-		if buffer.IsReadError(err) && buffer.CauseReadError(err) == io.EOF {
+		if buffer.IsReadError(err) && errors.Is(err, io.EOF) {
 			err = nil
 		}
 
@@ -4,6 +4,7 @@ import (
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/io"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
@@ -48,7 +49,7 @@ func (s *server) Dispatch(content session.Content, address net.Address) (transpo
 			target:       address,
 			transferType: transferType,
 		}, content, s.dispatcher); err != nil {
-			newError("failed to handle Input").WithError(err).AtDebug().Logging()
+			newError("failed to handle Input").WithError(err).WithSession(content).AtDebug().Logging()
 		}
 	}()
 
@@ -131,7 +132,7 @@ func (s *server) handleStatusKeep(manager *sessionManager, meta frameMetadata, r
 		rr := NewReader(reader, protocol.TransferTypeFromNetwork(body.target.Network))
 
 		err := buffer.Copy(body.link.Writer, rr)
-		if buffer.IsReadError(err) && buffer.CauseReadError(err) == io.EOF {
+		if buffer.IsReadError(err) && errors.Is(err, io.EOF) {
 			err = nil
 		}
 
@@ -209,7 +210,7 @@ func (s *server) handleStatusNew(manager *sessionManager, meta sessionWriterMeta
 		rr := NewReader(reader, protocol.TransferTypeFromNetwork(meta2.target.Network))
 
 		err := buffer.Copy(body.link.Writer, rr)
-		if buffer.IsReadError(err) && buffer.CauseReadError(err) == io.EOF {
+		if buffer.IsReadError(err) && errors.Is(err, io.EOF) {
 			err = nil
 		}
 
@@ -47,6 +47,19 @@ func (m *sessionsManager) New(target net.Address, link transport.Link) *sessionM
 	return manager
 }
 
+// Close closes the links of the mux connections, their sessions end with them.
+func (m *sessionsManager) Close() error {
+	m.pool.Range(func(_, manager interface{}) bool {
+		link := manager.(*sessionManager).link
+		_ = link.Writer.Close()
+		if closer, ok := link.Reader.(interface{ Close() error }); ok {
+			_ = closer.Close()
+		}
+		return true
+	})
+	return nil
+}
+
 func (m *sessionsManager) IDGen() sessionID {
 	return uint16(m.idGen.Add(1))
 }
@@ -5,11 +5,11 @@ import (
 	"crypto/aes"
 	"crypto/tls"
 	"encoding/binary"
+	"sort"
 
 	"golang.org/x/crypto/hkdf"
 
 	"v2ray.com/core/common/buffer"
-	"v2ray.com/core/common/bytespool"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol/sniffer"
 	tls_proto "v2ray.com/core/common/protocol/tls"
@@ -20,6 +20,9 @@ import (
 const (
 	versionDraft29 uint32 = 0xff00001d
 	version1       uint32 = 0x1
+
+	// maxCryptoLen bounds the reassembled client hello, a post-quantum one is about 2k.
+	maxCryptoLen = 16 * 1024
 )
 
 var (
@@ -49,86 +52,158 @@ func (s *quicSniffer) Protocol() sniffer.Protocol {
 }
 
 func (s *quicSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
-	frameData, err := getFrameData(b.Bytes())
+	frames, err := readCryptoFrames(b.Bytes())
 	if err != nil {
 		return sniffer.SniffResult{}, err
 	}
 
-	domain, err := tls_proto.ReadClientHello(frameData)
+	data, err := assembleCryptoFrames(frames)
+	if err != nil {
+		return sniffer.SniffResult{}, err
+	}
+
+	hello, err := tls_proto.ParseClientHello(data)
 	if err != nil {
 		return sniffer.SniffResult{}, err
 	}
 
 	return sniffer.SniffResult{
 		Protocol: s.Protocol(),
-		Domain:   domain,
+		Domain:   hello.ServerName,
+		ALPN:     hello.ALPN,
 	}, nil
 }
 
-func getFrameData(b []byte) ([]byte, error) {
+type cryptoFrame struct {
+	offset uint64
+	data   []byte
+}
+
+// readCryptoFrames returns the crypto frames of all initial packets in b, the packets may come from several datagrams.
+func readCryptoFrames(b []byte) ([]cryptoFrame, error) {
+	var frames []cryptoFrame
+
+	for len(b) > 0 {
+		n, packetFrames, err := readInitialPacket(b)
+		if err != nil {
+			if len(frames) > 0 {
+				break
+			}
+			return nil, err
+		}
+
+		frames = append(frames, packetFrames...)
+		b = b[n:]
+
+		// a datagram may be padded by zeros after its packets
+		for len(b) > 0 && b[0] == 0x0 {
+			b = b[1:]
+		}
+	}
+
+	return frames, nil
+}
+
+// assembleCryptoFrames returns the client hello message if the crypto frames carry all of it.
+func assembleCryptoFrames(frames []cryptoFrame) ([]byte, error) {
+	sort.SliceStable(frames, func(i, j int) bool {
+		return frames[i].offset < frames[j].offset
+	})
+
+	data := make([]byte, 0, maxCryptoLen)
+	for _, f := range frames {
+		if f.offset > uint64(len(data)) {
+			break
+		}
+		if end := f.offset + uint64(len(f.data)); end > uint64(len(data)) {
+			data = append(data, f.data[uint64(len(data))-f.offset:]...)
+		}
+	}
+
+	if len(data) < 4 {
+		return nil, sniffer.ErrNeedMore
+	}
+	if data[0] != 0x1 /* client hello */ {
+		return nil, errNotQuicInitial
+	}
+
+	msgLen := 4 + (int(data[1])<<16 | int(data[2])<<8 | int(data[3]))
+	if msgLen > maxCryptoLen {
+		return nil, newError("unexpected client hello length %d", msgLen)
+	}
+	if len(data) < msgLen {
+		return nil, sniffer.ErrNeedMore
+	}
+
+	return data[:msgLen], nil
+}
+
+// readInitialPacket returns the length and the crypto frames of the initial packet at the beginning of b.
+// b is never modified, the header protection is removed on a copy.
+func readInitialPacket(b []byte) (int, []cryptoFrame, error) {
 	buf := buffer.FromBytes(b)
 
-	bytes, err := buf.ReadByte()
+	typeByte, err := buf.ReadByte()
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	isLongHeader := bytes&0x80 > 0
-	if !isLongHeader || bytes&0x40 == 0 {
-		return nil, errNotQuicInitial
+	isLongHeader := typeByte&0x80 > 0
+	if !isLongHeader || typeByte&0x40 == 0 {
+		return 0, nil, errNotQuicInitial
 	}
 
 	vb, err := buf.ReadBytes(4)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	versionNumber := binary.BigEndian.Uint32(vb)
-	if versionNumber != 0 && bytes&0x40 == 0 {
-		return nil, errNotQuic
-	}
 	if versionNumber != versionDraft29 && versionNumber != version1 {
-		return nil, errNotQuic
+		return 0, nil, errNotQuic
 	}
 
-	if (bytes&0x30)>>4 != 0x0 {
-		return nil, errNotQuicInitial
+	if (typeByte&0x30)>>4 != 0x0 {
+		return 0, nil, errNotQuicInitial
 	}
 
 	idLen, err := buf.ReadByte()
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	connID, err := buf.ReadBytes(int(idLen))
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	if _, err = buf.ReadByte(); err != nil {
-		return nil, err
+	srcIDLen, err := buf.ReadByte()
+	if err != nil {
+		return 0, nil, err
 	}
-	if _, err = buf.ReadBytes(int(idLen)); err != nil {
-		return nil, err
+	if _, err = buf.ReadBytes(int(srcIDLen)); err != nil {
+		return 0, nil, err
 	}
 
 	tokenLen, err := quicvarint.Read(buf)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	if tokenLen > uint64(len(b)) {
-		return nil, errNotQuic
+		return 0, nil, errNotQuic
 	}
 	if _, err = buf.ReadBytes(int(tokenLen)); err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
 	packetLen, err := quicvarint.Read(buf)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
 	hdrLen := len(b) - buf.Len()
-	origPNBytes := make([]byte, 4)
-	copy(origPNBytes, b[hdrLen:hdrLen+4])
+	if packetLen > uint64(len(b)-hdrLen) || packetLen < 4+16 {
+		return 0, nil, errNotQuic
+	}
+	end := hdrLen + int(packetLen)
 
 	salt := quicSaltOld
 	if versionNumber == version1 {
@@ -138,73 +213,67 @@ func getFrameData(b []byte) ([]byte, error) {
 	initialSecret := hkdf.Extract(crypto.SHA256.New, connID, salt)
 	secret, err := hkdfExpandLabel(crypto.SHA256, initialSecret, []byte{}, "client in", crypto.SHA256.Size())
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	hpKey, err := hkdfExpandLabel(initialSuite.Hash, secret, []byte{}, "quic hp", initialSuite.KeyLen)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	block, err := aes.NewCipher(hpKey)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	cache := buffer.New()
-	defer cache.Release()
-
-	mask := cache.Extend(block.BlockSize())
+	mask := make([]byte, block.BlockSize())
 	block.Encrypt(mask, b[hdrLen+4:hdrLen+4+16])
-	b[0] ^= mask[0] & 0xf
-	for i := range b[hdrLen : hdrLen+4] {
-		b[hdrLen+i] ^= mask[i+1]
-	}
-	packetNumberLength := b[0]&0x3 + 1
-	if packetNumberLength != 1 {
-		return nil, errNotQuicInitial
-	}
 
-	pn, err := buf.ReadByte()
-	if err != nil {
-		return nil, err
-	}
-	packetNumber := uint32(pn)
-	if packetNumber != 0 && packetNumber != 1 {
-		return nil, errNotQuicInitial
+	header := make([]byte, hdrLen+4)
+	copy(header, b)
+	header[0] ^= mask[0] & 0xf
+
+	packetNumberLength := int(header[0]&0x3 + 1)
+	packetNumber := uint64(0)
+	for i := 0; i < packetNumberLength; i++ {
+		header[hdrLen+i] ^= mask[i+1]
+		packetNumber = packetNumber<<8 | uint64(header[hdrLen+i])
 	}
 
-	extHdrLen := hdrLen + int(packetNumberLength)
-	copy(b[extHdrLen:hdrLen+4], origPNBytes[packetNumberLength:])
-	data := b[extHdrLen : int(packetLen)+hdrLen]
+	extHdrLen := hdrLen + packetNumberLength
+	header = header[:extHdrLen]
 
 	key, err := hkdfExpandLabel(crypto.SHA256, secret, []byte{}, "quic key", 16)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	iv, err := hkdfExpandLabel(crypto.SHA256, secret, []byte{}, "quic iv", 12)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 	cipher := AEADAESGCMTLS13(key, iv)
-	nonce := cache.Extend(cipher.NonceSize())
-	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], uint64(packetNumber))
-	decrypted, err := cipher.Open(b[extHdrLen:extHdrLen], nonce, data, b[:extHdrLen])
+	nonce := make([]byte, cipher.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], packetNumber)
+	decrypted, err := cipher.Open(nil, nonce, b[extHdrLen:end], header)
 	if err != nil {
-		return nil, err
+		return 0, nil, err
 	}
 
-	buf = buffer.FromBytes(decrypted)
+	frames, err := readFrames(decrypted)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return end, frames, nil
+}
 
-	cryptoLen := uint(0)
-	cryptoData := bytespool.Alloc(buf.Len())
-	defer bytespool.Free(cryptoData)
+func readFrames(decrypted []byte) ([]cryptoFrame, error) {
+	var frames []cryptoFrame
+
+	buf := buffer.FromBytes(decrypted)
 
 	for !buf.IsEmpty() {
-		frameType := byte(0x0) // Default to PADDING frame
-		for frameType == 0x0 && !buf.IsEmpty() {
-			frameType, err = buf.ReadByte()
-			if err != nil {
-				return nil, err
-			}
+		frameType, err := buf.ReadByte()
+		if err != nil {
+			return nil, err
 		}
 
 		switch frameType {
@@ -252,15 +321,17 @@ func getFrameData(b []byte) ([]byte, error) {
 			if err != nil {
 				return nil, err
 			}
-			if length > uint64(buf.Len()) {
-				return nil, newError("unexpected length %d", length)
-			}
-			if cryptoLen < uint(offset+length) {
-				cryptoLen = uint(offset + length)
+			if length > uint64(buf.Len()) || offset+length > maxCryptoLen {
+				return nil, newError("unexpected length %d at offset %d", length, offset)
 			}
-			if _, err := buf.Read(cryptoData[offset : offset+length]); err != nil { // Field: Crypto Data
+			data, err := buf.ReadBytes(int(length)) // Field: Crypto Data
+			if err != nil {
 				return nil, err
 			}
+			frames = append(frames, cryptoFrame{
+				offset: offset,
+				data:   data,
+			})
 		case 0x1c: // CONNECTION_CLOSE frame, only 0x1c is permitted in initial packet
 			if _, err := quicvarint.Read(buf); err != nil { // Field: Error Code
 				return nil, err
@@ -282,7 +353,7 @@ func getFrameData(b []byte) ([]byte, error) {
 		}
 	}
 
-	return cryptoData[:cryptoLen], nil
+	return frames, nil
 }
 
 func hkdfExpandLabel(hash crypto.Hash, secret, ctx []byte, label string, length int) ([]byte, error) {
@@ -10,6 +10,8 @@ type User struct {
 	Security Security
 	Password Password
 	IvCheck  bool
+	Level    uint32
+	Email    string
 
 	replayFilter antireplay.GeneralizedReplayFilter
 }
@@ -5,6 +5,12 @@ import (
 	"v2ray.com/core/common/net"
 )
 
+var (
+	// ErrNeedMore is returned by a sniffer if the protocol is not decided by the cached payload,
+	// more payload of the connection is read and sniffed again until the sniffing limits.
+	ErrNeedMore = newError("need more payload")
+)
+
 type Protocol byte
 
 const (
@@ -12,16 +18,54 @@ const (
 	HTTP
 	TLS
 	QUIC
+	H2C
+	BitTorrent
+	SSH
+	STUN
+	DTLS
+	DNS
 )
 
+func (p Protocol) String() string {
+	switch p {
+	case Fake:
+		return "fakedns"
+	case HTTP:
+		return "http"
+	case TLS:
+		return "tls"
+	case QUIC:
+		return "quic"
+	case H2C:
+		return "h2c"
+	case BitTorrent:
+		return "bittorrent"
+	case SSH:
+		return "ssh"
+	case STUN:
+		return "stun"
+	case DTLS:
+		return "dtls"
+	case DNS:
+		return "dns"
+	default:
+		return "unknown"
+	}
+}
+
 type Sniffer interface {
 	Protocol() Protocol
 	Sniff(*buffer.Buffer, net.IP) (SniffResult, error)
 }
 
+// SniffResult is the sniffed protocol, with the domain if the protocol carries one.
 type SniffResult struct {
 	Protocol Protocol
 	Domain   string
+	// ALPN of the tls or quic client hello
+	ALPN []string
+	// Method of the http request
+	Method string
 }
 
 func (s SniffResult) AsAddress(address net.Address) net.Address {
@@ -34,3 +78,5 @@ func (s SniffResult) AsAddress(address net.Address) net.Address {
 func (s SniffResult) IsValid() bool {
 	return len(s.Domain) > 0
 }
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -0,0 +1,48 @@
+package ssh
+
+import (
+	"bytes"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+var (
+	// https://www.rfc-editor.org/rfc/rfc4253#section-4.2
+	bannerHeader = []byte("SSH-")
+	bannerV2     = []byte("SSH-2.0-")
+	bannerV199   = []byte("SSH-1.99-")
+
+	errNotSSH = newError("not ssh")
+)
+
+type sshSniffer struct {
+}
+
+func NewSniffer() sniffer.Sniffer {
+	return &sshSniffer{}
+}
+
+func (s *sshSniffer) Protocol() sniffer.Protocol {
+	return sniffer.SSH
+}
+
+func (s *sshSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if len(b0) < len(bannerV199) {
+		if bytes.HasPrefix(bannerHeader, b0) || bytes.HasPrefix(b0, bannerHeader) {
+			return sniffer.SniffResult{}, sniffer.ErrNeedMore
+		}
+		return sniffer.SniffResult{}, errNotSSH
+	}
+
+	if !bytes.HasPrefix(b0, bannerV2) && !bytes.HasPrefix(b0, bannerV199) {
+		return sniffer.SniffResult{}, errNotSSH
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
@@ -0,0 +1,57 @@
+package stun
+
+import (
+	"encoding/binary"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+// https://www.rfc-editor.org/rfc/rfc5389#section-6
+const (
+	headerLen   = 20
+	magicCookie = 0x2112A442
+)
+
+var (
+	errNotSTUN = newError("not stun")
+)
+
+type stunSniffer struct {
+}
+
+func NewSniffer() sniffer.Sniffer {
+	return &stunSniffer{}
+}
+
+func (s *stunSniffer) Protocol() sniffer.Protocol {
+	return sniffer.STUN
+}
+
+func (s *stunSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if len(b0) < headerLen {
+		return sniffer.SniffResult{}, errNotSTUN
+	}
+
+	// the most significant 2 bits of every message are zeroes
+	if b0[0]&0xc0 != 0 {
+		return sniffer.SniffResult{}, errNotSTUN
+	}
+
+	if binary.BigEndian.Uint32(b0[4:8]) != magicCookie {
+		return sniffer.SniffResult{}, errNotSTUN
+	}
+
+	// the attributes are padded to 4 bytes
+	length := int(binary.BigEndian.Uint16(b0[2:4]))
+	if length%4 != 0 || headerLen+length != len(b0) {
+		return sniffer.SniffResult{}, errNotSTUN
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
@@ -0,0 +1,3 @@
+package stun
+
+//go:generate go run v2ray.com/core/common/errors/errorgen
@@ -34,116 +34,180 @@ func (s *tlsSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, err
 		return sniffer.SniffResult{}, err
 	}
 
-	domain, err := ReadClientHello(data)
+	hello, err := ParseClientHello(data)
 	if err != nil {
 		return sniffer.SniffResult{}, err
 	}
 
 	return sniffer.SniffResult{
 		Protocol: s.Protocol(),
-		Domain:   domain,
+		Domain:   hello.ServerName,
+		ALPN:     hello.ALPN,
 	}, nil
 }
 
+// ClientHello is the sniffed extensions of a TLS client hello message.
+type ClientHello struct {
+	ServerName string
+	ALPN       []string
+}
+
 // ReadClientHello returns server name (if any) from TLS client hello message.
-// https://github.com/golang/go/blob/master/src/crypto/tls/handshake_messages.go#L300
 func ReadClientHello(data []byte) (string, error) {
+	hello, err := ParseClientHello(data)
+	if err != nil {
+		return "", err
+	}
+	if len(hello.ServerName) == 0 {
+		return "", errNotTLS
+	}
+	return hello.ServerName, nil
+}
+
+// ParseClientHello returns server name and alpn (if any) from TLS client hello message.
+// https://github.com/golang/go/blob/master/src/crypto/tls/handshake_messages.go#L300
+func ParseClientHello(data []byte) (ClientHello, error) {
+	hello := ClientHello{}
+
 	if len(data) < 42 {
-		return "", errNoClue
+		return hello, errNoClue
 	}
 
 	sessionIDLen := int(data[38])
 	if sessionIDLen > 32 || len(data) < 39+sessionIDLen {
-		return "", errNoClue
+		return hello, errNoClue
 	}
 	data = data[39+sessionIDLen:]
 	if len(data) < 2 {
-		return "", errNoClue
+		return hello, errNoClue
 	}
 
 	// cipherSuiteLen is the number of bytes of cipher suite numbers. Since
 	// they are uint16s, the number must be even.
 	cipherSuiteLen := int(data[0])<<8 | int(data[1])
 	if cipherSuiteLen%2 == 1 || len(data) < 2+cipherSuiteLen {
-		return "", errNotClientHello
+		return hello, errNotClientHello
 	}
 	data = data[2+cipherSuiteLen:]
 	if len(data) < 1 {
-		return "", errNoClue
+		return hello, errNoClue
 	}
 
 	compressionMethodsLen := int(data[0])
 	if len(data) < 1+compressionMethodsLen {
-		return "", errNoClue
+		return hello, errNoClue
 	}
 	data = data[1+compressionMethodsLen:]
 	if len(data) == 0 {
-		return "", errNotClientHello
+		return hello, errNotClientHello
 	}
 	if len(data) < 2 {
-		return "", errNotClientHello
+		return hello, errNotClientHello
 	}
 
 	extensionsLength := int(data[0])<<8 | int(data[1])
 	data = data[2:]
 	if extensionsLength != len(data) {
-		return "", errNotClientHello
+		return hello, errNotClientHello
 	}
 
 	for len(data) != 0 {
 		if len(data) < 4 {
-			return "", errNotClientHello
+			return hello, errNotClientHello
 		}
 
 		extension := uint16(data[0])<<8 | uint16(data[1])
 		length := int(data[2])<<8 | int(data[3])
 		data = data[4:]
 		if len(data) < length {
-			return "", errNotClientHello
+			return hello, errNotClientHello
 		}
 
-		if extension == 0x00 { /* extensionServerName */
-			d := data[:length]
-			if len(d) < 2 {
-				return "", errNotClientHello
+		switch extension {
+		case 0x00: /* extensionServerName */
+			serverName, err := readServerName(data[:length])
+			if err != nil {
+				return hello, err
+			}
+			hello.ServerName = serverName
+		case 0x10: /* extensionALPN */
+			alpn, err := readALPN(data[:length])
+			if err != nil {
+				return hello, err
 			}
+			hello.ALPN = alpn
+		}
+		data = data[length:]
+	}
 
-			namesLen := int(d[0])<<8 | int(d[1])
-			d = d[2:]
-			if len(d) != namesLen {
+	return hello, nil
+}
+
+func readServerName(d []byte) (string, error) {
+	if len(d) < 2 {
+		return "", errNotClientHello
+	}
+
+	namesLen := int(d[0])<<8 | int(d[1])
+	d = d[2:]
+	if len(d) != namesLen {
+		return "", errNotClientHello
+	}
+
+	for len(d) > 0 {
+		if len(d) < 3 {
+			return "", errNotClientHello
+		}
+
+		nameType := d[0]
+		nameLen := int(d[1])<<8 | int(d[2])
+		d = d[3:]
+		if len(d) < nameLen {
+			return "", errNotClientHello
+		}
+
+		if nameType == 0 {
+			serverName := string(d[:nameLen])
+			// An SNI value may not include a
+			// trailing dot. See
+			// https://tools.ietf.org/html/rfc6066#section-3.
+			if dns.IsFqdn(serverName) {
 				return "", errNotClientHello
 			}
+			return serverName, nil
+		}
 
-			for len(d) > 0 {
-				if len(d) < 3 {
-					return "", errNotClientHello
-				}
-
-				nameType := d[0]
-				nameLen := int(d[1])<<8 | int(d[2])
-				d = d[3:]
-				if len(d) < nameLen {
-					return "", errNotClientHello
-				}
-
-				if nameType == 0 {
-					serverName := string(d[:nameLen])
-					// An SNI value may not include a
-					// trailing dot. See
-					// https://tools.ietf.org/html/rfc6066#section-3.
-					if dns.IsFqdn(serverName) {
-						return "", errNotClientHello
-					}
-					return serverName, nil
-				}
-
-				d = d[nameLen:]
-			}
+		d = d[nameLen:]
+	}
+
+	return "", nil
+}
+
+// https://www.rfc-editor.org/rfc/rfc7301#section-3.1
+func readALPN(d []byte) ([]string, error) {
+	if len(d) < 2 {
+		return nil, errNotClientHello
+	}
+
+	protocolsLen := int(d[0])<<8 | int(d[1])
+	d = d[2:]
+	if len(d) != protocolsLen {
+		return nil, errNotClientHello
+	}
+
+	var alpn []string
+	for len(d) > 0 {
+		protocolLen := int(d[0])
+		d = d[1:]
+		if protocolLen == 0 || len(d) < protocolLen {
+			return nil, errNotClientHello
 		}
-		data = data[length:]
+
+		alpn = append(alpn, string(d[:protocolLen]))
+		d = d[protocolLen:]
 	}
 
-	return "", errNotTLS
+	return alpn, nil
 }
 
 func beginWithTLS(b []byte) ([]byte, error) {
@@ -0,0 +1,71 @@
+package tls
+
+import (
+	"encoding/binary"
+
+	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
+)
+
+// https://www.rfc-editor.org/rfc/rfc6347#section-4.1
+const (
+	dtlsRecordHeaderLen  = 13
+	dtlsContentHandshake = 22
+	dtlsClientHello      = 1
+)
+
+var (
+	errNotDTLS = newError("not DTLS header")
+)
+
+type dtlsSniffer struct {
+}
+
+// NewDTLSSniffer sniffs the client hello of DTLS, like the handshake of WebRTC.
+func NewDTLSSniffer() sniffer.Sniffer {
+	return &dtlsSniffer{}
+}
+
+func (s *dtlsSniffer) Protocol() sniffer.Protocol {
+	return sniffer.DTLS
+}
+
+func (s *dtlsSniffer) Sniff(b *buffer.Buffer, _ net.IP) (sniffer.SniffResult, error) {
+	b0 := b.Bytes()
+
+	if len(b0) < dtlsRecordHeaderLen+1 {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	if b0[0] != dtlsContentHandshake {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	if !IsValidDTLSVersion(b0[1], b0[2]) {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	// the client hello starts at epoch 0
+	if binary.BigEndian.Uint16(b0[3:5]) != 0 {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	length := int(binary.BigEndian.Uint16(b0[11:13]))
+	if dtlsRecordHeaderLen+length > len(b0) {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	if b0[dtlsRecordHeaderLen] != dtlsClientHello {
+		return sniffer.SniffResult{}, errNotDTLS
+	}
+
+	return sniffer.SniffResult{
+		Protocol: s.Protocol(),
+	}, nil
+}
+
+// IsValidDTLSVersion checks DTLS 1.0 (0xfeff) and 1.2 (0xfefd), 1.3 records keep 1.2.
+func IsValidDTLSVersion(major, minor byte) bool {
+	return major == 0xfe && (minor == 0xff || minor == 0xfd)
+}
@@ -4,8 +4,6 @@ type User struct {
 	Security Security
 	ID       ID
 	AlterIDs []ID
-}
-
-func (u User) Email() string {
-	return ""
+	Level    uint32
+	Email    string
 }
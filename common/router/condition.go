@@ -2,24 +2,22 @@ package router
 
 import (
 	"net/netip"
+	"time"
 
 	"v2ray.com/core/common/net"
 )
 
-type Condition []ConditionBody
-
-func (c Condition) MatchString(name Name, s string) bool {
-	for _, body := range c {
-		if body.Name == name && !body.MatchString(s) {
-			return false
-		}
-	}
-	return true
+// Content provides the values of a connection to be matched by condition bodies.
+type Content interface {
+	MatchBody(ConditionBody) bool
 }
 
-func (c Condition) MatchIP(name Name, s net.IP) bool {
+// Condition matches if all of its bodies match.
+type Condition []ConditionBody
+
+func (c Condition) Match(content Content) bool {
 	for _, body := range c {
-		if body.Name == name && !body.MatchIP(s) {
+		if !body.Match(content) {
 			return false
 		}
 	}
@@ -36,11 +34,50 @@ const (
 	Regex
 )
 
+// ConditionBody matches if any of its strings or cidrs matches the value of its name.
+// A body with Any or All is a group, its name is ignored then.
 type ConditionBody struct {
-	Name   Name
-	Length Length
-	String []string
-	CIDR   []netip.Prefix
+	Name     Name
+	Length   Length
+	String   []string
+	CIDR     []netip.Prefix
+	Schedule []Schedule
+
+	// Not negates the result of the body.
+	Not bool
+	// Any matches if any of the conditions matches.
+	Any []Condition
+	// All matches if all of the bodies match.
+	All Condition
+}
+
+func (c ConditionBody) IsGroup() bool {
+	return len(c.Any) > 0 || len(c.All) > 0
+}
+
+func (c ConditionBody) Match(content Content) bool {
+	return c.match(content) != c.Not
+}
+
+func (c ConditionBody) match(content Content) bool {
+	if !c.IsGroup() {
+		return content.MatchBody(c)
+	}
+
+	if len(c.All) > 0 && !c.All.Match(content) {
+		return false
+	}
+
+	if len(c.Any) > 0 {
+		for _, cond := range c.Any {
+			if cond.Match(content) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
 }
 
 func (c ConditionBody) MatchString(s string) bool {
@@ -67,3 +104,7 @@ func (c ConditionBody) MatchIP(s net.IP) bool {
 		return false
 	}
 }
+
+func (c ConditionBody) MatchTime(t time.Time) bool {
+	return MatchSchedule(c.Schedule, t)
+}
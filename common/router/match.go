@@ -4,6 +4,7 @@ import (
 	"net/netip"
 	"regexp"
 	"strings"
+	"time"
 )
 
 func MatchIP(cidr []netip.Prefix, ip netip.Addr) bool {
@@ -17,6 +18,17 @@ func MatchIP(cidr []netip.Prefix, ip netip.Addr) bool {
 	return false
 }
 
+func MatchSchedule(schedule []Schedule, t time.Time) bool {
+	if !t.IsZero() {
+		for _, s := range schedule {
+			if s.Match(t) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func MatchRegexString(str []string, s string) bool {
 	if len(s) > 0 {
 		for _, reg := range str {
@@ -0,0 +1,67 @@
+package router
+
+import (
+	"time"
+)
+
+// TimeRange is a window of a day, as offsets since midnight.
+// A range with To before From wraps midnight, like 22:00-02:00.
+type TimeRange struct {
+	From, To time.Duration
+}
+
+func (r TimeRange) wraps() bool {
+	return r.To < r.From
+}
+
+// Schedule matches a time inside any of its ranges on any of its days.
+// Empty days match every day, empty ranges match the whole day.
+type Schedule struct {
+	Days     []time.Weekday
+	Ranges   []TimeRange
+	Location *time.Location
+}
+
+func (s Schedule) Match(t time.Time) bool {
+	if s.Location != nil {
+		t = t.In(s.Location)
+	}
+
+	if len(s.Ranges) == 0 {
+		return s.matchDay(t.Weekday())
+	}
+
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+
+	for _, r := range s.Ranges {
+		switch {
+		case !r.wraps():
+			if offset >= r.From && offset < r.To && s.matchDay(t.Weekday()) {
+				return true
+			}
+		case offset >= r.From:
+			if s.matchDay(t.Weekday()) {
+				return true
+			}
+		case offset < r.To:
+			// the early hours belong to the window started the day before
+			if s.matchDay((t.Weekday() + 6) % 7) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (s Schedule) matchDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
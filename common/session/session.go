@@ -2,8 +2,10 @@ package session
 
 import (
 	"math/rand"
+	"time"
 
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol/sniffer"
 )
 
 // ID of a session.
@@ -28,9 +30,80 @@ type Inbound struct {
 	Gateway net.Address
 	// Tag of the inbound proxy that handles the connection.
 	Tag string
+	// Level of the user authenticated by the inbound proxy.
+	Level uint32
+	// Email of the user authenticated by the inbound proxy.
+	Email string
 }
 
 type Mux struct {
 	// Enabled show the mux outbound is used
 	Enabled bool
 }
+
+// Sniffing is the sniffing setting of the inbound connection.
+type Sniffing struct {
+	// Enabled show the destination is sniffed
+	Enabled bool
+	// OverrideProtocols are the sniffed protocols overriding the destination
+	OverrideProtocols []sniffer.Protocol
+	// ExcludedDomains are the sniffed domains never overriding the destination
+	ExcludedDomains []string
+	// RouteOnly show the sniffed domain is only used for routing
+	RouteOnly bool
+	// Timeout of waiting the first payload, the original destination is routed after it, zero waits until it comes
+	Timeout time.Duration
+}
+
+// Sniffed is the sniffed result of the first payload of the connection.
+type Sniffed struct {
+	// Protocol of the first payload
+	Protocol sniffer.Protocol
+	// Domain carried by the protocol, empty if none
+	Domain string
+	// ALPN of the tls or quic client hello
+	ALPN []string
+	// Method of the http request
+	Method string
+}
+
+// DefaultSniffing overrides the destination by all sniffed protocols.
+func DefaultSniffing() Sniffing {
+	return Sniffing{
+		Enabled:           true,
+		OverrideProtocols: []sniffer.Protocol{sniffer.Fake, sniffer.HTTP, sniffer.TLS, sniffer.QUIC, sniffer.H2C},
+	}
+}
+
+// Policy is the connection policy of the inbound connection, a zero timeout never expires.
+type Policy struct {
+	// Handshake timeout of reading the request by the inbound proxy
+	Handshake time.Duration
+	// ConnIdle timeout of no payload either way
+	ConnIdle time.Duration
+	// UplinkOnly timeout after the downlink is closed
+	UplinkOnly time.Duration
+	// DownlinkOnly timeout after the uplink is closed
+	DownlinkOnly time.Duration
+	// Levels are the policies of the user levels
+	Levels map[uint32]Policy
+}
+
+// ForLevel returns the policy of the user level, or the policy itself.
+func (p Policy) ForLevel(level uint32) Policy {
+	if policy, ok := p.Levels[level]; ok {
+		return policy
+	}
+	return p
+}
+
+// DefaultPolicy is the base of a configured policy, it closes an idle connection after 5 minutes.
+// A zero Policy never closes a connection, which is the policy of an inbound without any configured.
+func DefaultPolicy() Policy {
+	return Policy{
+		Handshake:    4 * time.Second,
+		ConnIdle:     300 * time.Second,
+		UplinkOnly:   1 * time.Second,
+		DownlinkOnly: 1 * time.Second,
+	}
+}
@@ -1,7 +1,11 @@
 package session
 
 import (
+	"sync"
+
 	"v2ray.com/core/common/cache"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/log"
 )
 
 type sessionKey byte
@@ -10,6 +14,10 @@ const (
 	idSessionKey sessionKey = iota
 	inboundSessionKey
 	muxSessionKey
+	sniffingSessionKey
+	sniffedSessionKey
+	policySessionKey
+	closerSessionKey
 )
 
 type Content interface {
@@ -19,12 +27,30 @@ type Content interface {
 	GetInbound() (Inbound, bool)
 	SetMux(Mux)
 	GetMux() (Mux, bool)
+	SetSniffing(Sniffing)
+	GetSniffing() (Sniffing, bool)
+	SetSniffed(Sniffed)
+	GetSniffed() (Sniffed, bool)
+	SetPolicy(Policy)
+	GetPolicy() (Policy, bool)
+	// SetCloser sets the closer of the inbound connection, so that killing the session closes the connection.
+	SetCloser(io.Closer)
+	GetCloser() (io.Closer, bool)
+
+	// LogSession identifies the content in the log.
+	LogSession() log.Session
 
+	// OnClose registers a function called once the content is closed.
+	OnClose(func())
 	Close() error
 }
 
 type content struct {
 	cache.Pool
+
+	sync.Mutex
+	onClose []func()
+	closed  bool
 }
 
 func NewContent() Content {
@@ -65,3 +91,82 @@ func (c *content) GetMux() (Mux, bool) {
 	}
 	return Mux{}, false
 }
+
+func (c *content) SetSniffing(sniffing Sniffing) {
+	c.Set(sniffingSessionKey, sniffing)
+}
+
+func (c *content) GetSniffing() (Sniffing, bool) {
+	if sniffing, ok := c.Get(sniffingSessionKey); ok {
+		return sniffing.(Sniffing), true
+	}
+	return Sniffing{}, false
+}
+
+func (c *content) SetSniffed(sniffed Sniffed) {
+	c.Set(sniffedSessionKey, sniffed)
+}
+
+func (c *content) GetSniffed() (Sniffed, bool) {
+	if sniffed, ok := c.Get(sniffedSessionKey); ok {
+		return sniffed.(Sniffed), true
+	}
+	return Sniffed{}, false
+}
+
+func (c *content) SetPolicy(policy Policy) {
+	c.Set(policySessionKey, policy)
+}
+
+func (c *content) GetPolicy() (Policy, bool) {
+	if policy, ok := c.Get(policySessionKey); ok {
+		return policy.(Policy), true
+	}
+	return Policy{}, false
+}
+
+func (c *content) SetCloser(closer io.Closer) {
+	c.Set(closerSessionKey, closer)
+}
+
+func (c *content) GetCloser() (io.Closer, bool) {
+	if closer, ok := c.Get(closerSessionKey); ok {
+		return closer.(io.Closer), true
+	}
+	return nil, false
+}
+
+func (c *content) LogSession() log.Session {
+	id, _ := c.GetID()
+	inbound, _ := c.GetInbound()
+
+	return log.Session{
+		ID:      uint32(id),
+		Inbound: inbound.Tag,
+	}
+}
+
+func (c *content) OnClose(fn func()) {
+	c.Lock()
+	if !c.closed {
+		c.onClose = append(c.onClose, fn)
+		c.Unlock()
+		return
+	}
+	c.Unlock()
+
+	fn()
+}
+
+func (c *content) Close() error {
+	c.Lock()
+	onClose := c.onClose
+	c.onClose, c.closed = nil, true
+	c.Unlock()
+
+	for _, fn := range onClose {
+		fn()
+	}
+
+	return c.Pool.Close()
+}
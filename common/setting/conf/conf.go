@@ -2,6 +2,7 @@ package conf
 
 import (
 	"encoding/json"
+	"os"
 
 	"v2ray.com/core/assets"
 	"v2ray.com/core/common/io"
@@ -26,16 +27,22 @@ type config struct {
 	} `json:"dns,omitempty"`
 	Inbounds struct {
 		Dokodemo []struct {
-			Tag     string   `json:"tag,omitempty"`
-			Network []string `json:"network,omitempty"`
-			Listen  string   `json:"listen,omitempty"`
-			Mux     bool     `json:"mux,omitempty"`
+			Tag            string          `json:"tag,omitempty"`
+			Network        []string        `json:"network,omitempty"`
+			Listen         string          `json:"listen,omitempty"`
+			StreamSettings *streamConfig   `json:"streamSettings,omitempty"`
+			Mux            bool            `json:"mux,omitempty"`
+			Sniffing       *sniffingConfig `json:"sniffing,omitempty"`
+			Policy         *policyConfig   `json:"policy,omitempty"`
 		} `json:"dokodemo,omitempty"`
 		Http []struct {
-			Tag     string   `json:"tag,omitempty"`
-			Network []string `json:"network,omitempty"`
-			Listen  string   `json:"listen,omitempty"`
-			Mux     bool     `json:"mux,omitempty"`
+			Tag            string          `json:"tag,omitempty"`
+			Network        []string        `json:"network,omitempty"`
+			Listen         string          `json:"listen,omitempty"`
+			StreamSettings *streamConfig   `json:"streamSettings,omitempty"`
+			Mux            bool            `json:"mux,omitempty"`
+			Sniffing       *sniffingConfig `json:"sniffing,omitempty"`
+			Policy         *policyConfig   `json:"policy,omitempty"`
 		} `json:"http,omitempty"`
 		Shadowsocks []struct {
 			Tag     string   `json:"tag,omitempty"`
@@ -44,58 +51,48 @@ type config struct {
 			User    struct {
 				Security string `json:"security,omitempty"`
 				Password string `json:"password,omitempty"`
+				Level    uint32 `json:"level,omitempty"`
+				Email    string `json:"email,omitempty"`
 			} `json:"user,omitempty"`
-			Tcp struct {
-				Tls struct {
-					ServerName  string `json:"serverName,omitempty"`
-					Certificate string `json:"certificate,omitempty"`
-					Key         string `json:"key,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"tcp,omitempty"`
-			Websocket struct {
-				Path string `json:"path,omitempty"`
-				Tls  struct {
-					ServerName  string `json:"serverName,omitempty"`
-					Certificate string `json:"certificate,omitempty"`
-					Key         string `json:"key,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"websocket,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			StreamSettings *streamConfig    `json:"streamSettings,omitempty"`
+			Tcp            *tcpConfig       `json:"tcp,omitempty"`
+			Websocket      *websocketConfig `json:"websocket,omitempty"`
+			Mux            bool             `json:"mux,omitempty"`
+			Sniffing       *sniffingConfig  `json:"sniffing,omitempty"`
+			Policy         *policyConfig    `json:"policy,omitempty"`
 		} `json:"shadowsocks,omitempty"`
 		Socks []struct {
-			Tag     string   `json:"tag,omitempty"`
-			Network []string `json:"network,omitempty"`
-			Listen  string   `json:"listen,omitempty"`
-			Resp    string   `json:"resp,omitempty"`
-			Mux     bool     `json:"mux,omitempty"`
+			Tag            string          `json:"tag,omitempty"`
+			Network        []string        `json:"network,omitempty"`
+			Listen         string          `json:"listen,omitempty"`
+			Resp           string          `json:"resp,omitempty"`
+			StreamSettings *streamConfig   `json:"streamSettings,omitempty"`
+			Mux            bool            `json:"mux,omitempty"`
+			Sniffing       *sniffingConfig `json:"sniffing,omitempty"`
+			Policy         *policyConfig   `json:"policy,omitempty"`
 		} `json:"socks,omitempty"`
 		Tun []struct {
-			Tag     string   `json:"tag,omitempty"`
-			Network []string `json:"network,omitempty"`
+			Tag            string          `json:"tag,omitempty"`
+			Network        []string        `json:"network,omitempty"`
+			StreamSettings *streamConfig   `json:"streamSettings,omitempty"`
+			Sniffing       *sniffingConfig `json:"sniffing,omitempty"`
+			Policy         *policyConfig   `json:"policy,omitempty"`
 		} `json:"tun,omitempty"`
 		Vmess []struct {
 			Tag     string   `json:"tag,omitempty"`
 			Network []string `json:"network,omitempty"`
 			Listen  string   `json:"listen,omitempty"`
 			User    struct {
-				UUID string `json:"uuid,omitempty"`
+				UUID  string `json:"uuid,omitempty"`
+				Level uint32 `json:"level,omitempty"`
+				Email string `json:"email,omitempty"`
 			} `json:"user,omitempty"`
-			Tcp struct {
-				Tls struct {
-					ServerName  string `json:"serverName,omitempty"`
-					Certificate string `json:"certificate,omitempty"`
-					Key         string `json:"key,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"tcp,omitempty"`
-			Websocket struct {
-				Path string `json:"path,omitempty"`
-				Tls  struct {
-					ServerName  string `json:"serverName,omitempty"`
-					Certificate string `json:"certificate,omitempty"`
-					Key         string `json:"key,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"websocket,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			StreamSettings *streamConfig    `json:"streamSettings,omitempty"`
+			Tcp            *tcpConfig       `json:"tcp,omitempty"`
+			Websocket      *websocketConfig `json:"websocket,omitempty"`
+			Mux            bool             `json:"mux,omitempty"`
+			Sniffing       *sniffingConfig  `json:"sniffing,omitempty"`
+			Policy         *policyConfig    `json:"policy,omitempty"`
 		} `json:"vmess,omitempty"`
 	} `json:"inbounds,omitempty"`
 	Outbounds struct {
@@ -106,12 +103,14 @@ type config struct {
 			Tag string `json:"tag,omitempty"`
 		} `json:"dns,omitempty"`
 		Freedom []struct {
-			Tag string `json:"tag,omitempty"`
+			Tag            string        `json:"tag,omitempty"`
+			StreamSettings *streamConfig `json:"streamSettings,omitempty"`
 		} `json:"freedom,omitempty"`
 		Http []struct {
-			Tag    string `json:"tag,omitempty"`
-			Target string `json:"target,omitempty"`
-			Mux    bool   `json:"mux,omitempty"`
+			Tag            string        `json:"tag,omitempty"`
+			Target         string        `json:"target,omitempty"`
+			StreamSettings *streamConfig `json:"streamSettings,omitempty"`
+			Mux            bool          `json:"mux,omitempty"`
 		} `json:"http,omitempty"`
 		Shadowsocks []struct {
 			Tag    string `json:"tag,omitempty"`
@@ -120,39 +119,23 @@ type config struct {
 				Security string `json:"security,omitempty"`
 				Password string `json:"password,omitempty"`
 			} `json:"user,omitempty"`
-			Tcp struct {
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"tcp,omitempty"`
-			Websocket struct {
-				Path string `json:"path,omitempty"`
-				Tls  struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"websocket,omitempty"`
-			Forward struct {
-				Tag string `json:"tag,omitempty"`
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"forward,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			StreamSettings *streamConfig    `json:"streamSettings,omitempty"`
+			Tcp            *tcpConfig       `json:"tcp,omitempty"`
+			Websocket      *websocketConfig `json:"websocket,omitempty"`
+			Forward        forwardConfig    `json:"forward,omitempty"`
+			Mux            bool             `json:"mux,omitempty"`
 		} `json:"shadowsocks,omitempty"`
 		Socks []struct {
-			Tag    string `json:"tag,omitempty"`
-			Target string `json:"target,omitempty"`
-			Mux    bool   `json:"mux,omitempty"`
+			Tag            string        `json:"tag,omitempty"`
+			Target         string        `json:"target,omitempty"`
+			StreamSettings *streamConfig `json:"streamSettings,omitempty"`
+			Mux            bool          `json:"mux,omitempty"`
 		} `json:"socks,omitempty"`
 		Tor []struct {
-			Tag     string `json:"tag,omitempty"`
-			Forward struct {
-				Tag string `json:"tag,omitempty"`
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"forward,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			Tag            string        `json:"tag,omitempty"`
+			StreamSettings *streamConfig `json:"streamSettings,omitempty"`
+			Forward        forwardConfig `json:"forward,omitempty"`
+			Mux            bool          `json:"mux,omitempty"`
 		} `json:"tor,omitempty"`
 		Trojan []struct {
 			Tag    string `json:"tag,omitempty"`
@@ -160,24 +143,11 @@ type config struct {
 			User   struct {
 				Password string `json:"password,omitempty"`
 			} `json:"user,omitempty"`
-			Tcp struct {
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"tcp,omitempty"`
-			Websocket struct {
-				Path string `json:"path,omitempty"`
-				Tls  struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"websocket,omitempty"`
-			Forward struct {
-				Tag string `json:"tag,omitempty"`
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"forward,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			StreamSettings *streamConfig    `json:"streamSettings,omitempty"`
+			Tcp            *tcpConfig       `json:"tcp,omitempty"`
+			Websocket      *websocketConfig `json:"websocket,omitempty"`
+			Forward        forwardConfig    `json:"forward,omitempty"`
+			Mux            bool             `json:"mux,omitempty"`
 		} `json:"trojan,omitempty"`
 		Vmess []struct {
 			Tag    string `json:"tag,omitempty"`
@@ -186,56 +156,192 @@ type config struct {
 				Security string `json:"security,omitempty"`
 				UUID     string `json:"uuid,omitempty"`
 			} `json:"user,omitempty"`
-			Tcp struct {
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"tcp,omitempty"`
-			Websocket struct {
-				Path string `json:"path,omitempty"`
-				Tls  struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"websocket,omitempty"`
-			Forward struct {
-				Tag string `json:"tag,omitempty"`
-				Tls struct {
-					ServerName string `json:"serverName,omitempty"`
-				} `json:"tls,omitempty"`
-			} `json:"forward,omitempty"`
-			Mux bool `json:"mux,omitempty"`
+			StreamSettings *streamConfig    `json:"streamSettings,omitempty"`
+			Tcp            *tcpConfig       `json:"tcp,omitempty"`
+			Websocket      *websocketConfig `json:"websocket,omitempty"`
+			Forward        forwardConfig    `json:"forward,omitempty"`
+			Mux            bool             `json:"mux,omitempty"`
 		} `json:"vmess,omitempty"`
 	} `json:"outbounds,omitempty"`
+	Log struct {
+		Level    string            `json:"level,omitempty"`
+		Format   string            `json:"format,omitempty"`
+		Path     string            `json:"path,omitempty"`
+		Rotation logRotationConfig `json:"rotation,omitempty"`
+		Access   *struct {
+			Format   string            `json:"format,omitempty"`
+			Path     string            `json:"path,omitempty"`
+			Rotation logRotationConfig `json:"rotation,omitempty"`
+		} `json:"access,omitempty"`
+	} `json:"log,omitempty"`
+	Api struct {
+		Listen string `json:"listen,omitempty"`
+		Token  string `json:"token,omitempty"`
+	} `json:"api,omitempty"`
+	Stats struct {
+		Inbound  bool `json:"inbound,omitempty"`
+		Outbound bool `json:"outbound,omitempty"`
+		User     bool `json:"user,omitempty"`
+	} `json:"stats,omitempty"`
 	Rules struct {
-		Dns []struct {
-			Condition []struct {
-				Name   string   `json:"name,omitempty"`
-				Length string   `json:"length,omitempty"`
-				String []string `json:"string,omitempty"`
-			} `json:"condition,omitempty"`
-			OutboundTag string `json:"outboundTag,omitempty"`
+		DomainStrategy string `json:"domainStrategy,omitempty"`
+		Dns            []struct {
+			Condition   []ruleCondition `json:"condition,omitempty"`
+			OutboundTag string          `json:"outboundTag,omitempty"`
+			Priority    int             `json:"priority,omitempty"`
 		} `json:"dns,omitempty"`
 		Outbound []struct {
-			Condition []struct {
-				Name   string   `json:"name,omitempty"`
-				Length string   `json:"length,omitempty"`
-				String []string `json:"string,omitempty"`
-			} `json:"condition,omitempty"`
-			OutboundTag string `json:"outboundTag,omitempty"`
+			Condition   []ruleCondition `json:"condition,omitempty"`
+			OutboundTag string          `json:"outboundTag,omitempty"`
+			Priority    int             `json:"priority,omitempty"`
 		} `json:"outbound,omitempty"`
 	} `json:"rules,omitempty"`
 }
 
+type sniffingConfig struct {
+	Enabled         bool     `json:"enabled,omitempty"`
+	DestOverride    []string `json:"destOverride,omitempty"`
+	DomainsExcluded []string `json:"domainsExcluded,omitempty"`
+	RouteOnly       bool     `json:"routeOnly,omitempty"`
+	Timeout         string   `json:"timeout,omitempty"`
+}
+
+type policyConfig struct {
+	Handshake    string                  `json:"handshake,omitempty"`
+	ConnIdle     string                  `json:"connIdle,omitempty"`
+	UplinkOnly   string                  `json:"uplinkOnly,omitempty"`
+	DownlinkOnly string                  `json:"downlinkOnly,omitempty"`
+	Levels       map[string]policyConfig `json:"levels,omitempty"`
+}
+
+// streamConfig is the transport of an inbound or an outbound, the tls is enabled if it is present.
+type streamConfig struct {
+	Network string            `json:"network,omitempty"`
+	Path    string            `json:"path,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Tls     *tlsConfig        `json:"tls,omitempty"`
+	Sockopt sockoptConfig     `json:"sockopt,omitempty"`
+}
+
+type tlsConfig struct {
+	ServerName    string   `json:"serverName,omitempty"`
+	Certificate   string   `json:"certificate,omitempty"`
+	Key           string   `json:"key,omitempty"`
+	AllowInsecure bool     `json:"allowInsecure,omitempty"`
+	Alpn          []string `json:"alpn,omitempty"`
+}
+
+type sockoptConfig struct {
+	Mark      int    `json:"mark,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	KeepAlive string `json:"keepAlive,omitempty"`
+}
+
+// forwardConfig dials the outbound through the outbound of the tag, the tls is enabled if it has the serverName.
+type forwardConfig struct {
+	Tag string `json:"tag,omitempty"`
+	Tls struct {
+		ServerName string `json:"serverName,omitempty"`
+	} `json:"tls,omitempty"`
+}
+
+// tcpConfig and websocketConfig are kept for the old conf, the tls is enabled if it has the serverName.
+type tcpConfig struct {
+	Tls *tlsConfig `json:"tls,omitempty"`
+}
+
+type websocketConfig struct {
+	Path string     `json:"path,omitempty"`
+	Tls  *tlsConfig `json:"tls,omitempty"`
+}
+
+type logRotationConfig struct {
+	MaxSize    int64  `json:"maxSize,omitempty"`
+	MaxAge     string `json:"maxAge,omitempty"`
+	MaxBackups int    `json:"maxBackups,omitempty"`
+}
+
+type userConfig struct {
+	Email    string `json:"email,omitempty"`
+	Level    uint32 `json:"level,omitempty"`
+	UUID     string `json:"uuid,omitempty"`
+	Security string `json:"security,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type ruleCondition struct {
+	Name     string   `json:"name,omitempty"`
+	Length   string   `json:"length,omitempty"`
+	String   []string `json:"string,omitempty"`
+	Schedule []struct {
+		Days     []string `json:"days,omitempty"`
+		Ranges   []string `json:"ranges,omitempty"`
+		TimeZone string   `json:"timeZone,omitempty"`
+	} `json:"schedule,omitempty"`
+	Not bool              `json:"not,omitempty"`
+	Any [][]ruleCondition `json:"any,omitempty"`
+	All []ruleCondition   `json:"all,omitempty"`
+}
+
+// Check reads and validates the conf without loading it, the problems include the warnings.
+func Check() (Problems, error) {
+	c, err := unmarshal()
+	if err != nil {
+		return nil, err
+	}
+	return c.Validate(), nil
+}
+
+// unmarshal reads the conf file, then merges the files in the conf.d in lexical order.
+// The conf file is optional if the conf.d has any. A file is json, yaml or toml by its suffix.
 func unmarshal() (config, error) {
-	b, err := ReadBytes(assets.ConfFileFileReader)
+	files, err := assets.ConfDirFiles()
 	if err != nil {
+		return config{}, newError("failed to list conf dir").WithError(err)
+	}
+
+	merged := map[string]interface{}{}
+
+	b, err := ReadBytes(assets.ConfFileFileReader)
+	if err != nil && !(len(files) > 0 && os.IsNotExist(err)) {
 		return config{}, newError("failed to read file").WithError(err)
 	}
+	if err == nil {
+		if merged, err = decodeFile(assets.ConfFile(), b); err != nil {
+			return config{}, newError("failed to unmarshal file %s", assets.ConfFile()).WithError(err)
+		}
+	}
+
+	for _, file := range files {
+		b, err := ReadBytes(func() (io.ReadCloser, error) {
+			return assets.ConfFileReader(file)
+		})
+		if err != nil {
+			return config{}, newError("failed to read file %s", file).WithError(err)
+		}
+
+		object, err := decodeFile(file, b)
+		if err != nil {
+			return config{}, newError("failed to unmarshal file %s", file).WithError(err)
+		}
+
+		mergeObject(merged, object)
+	}
+
+	if err := interpolate(merged).Err(); err != nil {
+		return config{}, newError("failed to interpolate conf").WithError(err)
+	}
+
+	if b, err = json.Marshal(merged); err != nil {
+		return config{}, newError("failed to marshal conf").WithError(err)
+	}
 
 	c := config{}
 	if err := json.Unmarshal(b, &c); err != nil {
 		return config{}, newError("failed to unmarshal bytes").WithError(err)
 	}
+	c.sortRules()
+
 	return c, nil
 }
 
@@ -0,0 +1,146 @@
+package conf
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"v2ray.com/core/assets"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	Format_JSON = "json"
+	Format_YAML = "yaml"
+	Format_TOML = "toml"
+)
+
+// ParseFormat parses "json", "jsonc", "yaml", "yml" or "toml", empty is "json".
+func ParseFormat(s string) (string, error) {
+	switch s {
+	case "", Format_JSON, "jsonc":
+		return Format_JSON, nil
+	case Format_YAML, "yml":
+		return Format_YAML, nil
+	case Format_TOML:
+		return Format_TOML, nil
+	default:
+		return "", newError("unknown conf format %s", s)
+	}
+}
+
+// formatOf returns the format of the file by its suffix, unless the assets.ConfFormat overrides it.
+func formatOf(file string) (string, error) {
+	if len(assets.ConfFormat) > 0 {
+		return ParseFormat(assets.ConfFormat)
+	}
+
+	ext := filepath.Ext(file)
+	if len(ext) == 0 {
+		return Format_JSON, nil
+	}
+	return ParseFormat(ext[1:])
+}
+
+// decodeFile decodes the conf file into a json object, so that every format is merged and unmarshaled alike.
+func decodeFile(file string, b []byte) (map[string]interface{}, error) {
+	format, err := formatOf(file)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case Format_YAML:
+		object := map[string]interface{}{}
+		if err := yaml.Unmarshal(b, &object); err != nil {
+			return nil, err
+		}
+		return normalize(object).(map[string]interface{}), nil
+	case Format_TOML:
+		object := map[string]interface{}{}
+		if err := toml.Unmarshal(b, &object); err != nil {
+			return nil, err
+		}
+		return normalize(object).(map[string]interface{}), nil
+	default:
+		return decodeObject(stripComments(b))
+	}
+}
+
+// normalize converts the maps with any keys of yaml and the tables of toml into the json objects and arrays.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = normalize(e)
+		}
+		return v
+	case map[interface{}]interface{}:
+		object := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			object[fmt.Sprint(k)] = normalize(e)
+		}
+		return object
+	case []interface{}:
+		for i, e := range v {
+			v[i] = normalize(e)
+		}
+		return v
+	case []map[string]interface{}:
+		array := make([]interface{}, 0, len(v))
+		for _, e := range v {
+			array = append(array, normalize(e))
+		}
+		return array
+	default:
+		return v
+	}
+}
+
+// stripComments replaces the // and /* */ comments out of the strings by spaces, so that the offsets of the errors are kept.
+func stripComments(b []byte) []byte {
+	if !bytes.Contains(b, []byte("/")) {
+		return b
+	}
+
+	out := make([]byte, len(b))
+	copy(out, b)
+
+	blank := func(i, j int) {
+		for ; i < j; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			j := bytes.IndexByte(out[i:], '\n')
+			if j < 0 {
+				j = len(out) - i
+			}
+			blank(i, i+j)
+			i += j
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			j := bytes.Index(out[i+2:], []byte("*/"))
+			if j < 0 {
+				// the unclosed comment is left to fail the decoding
+				return out
+			}
+			blank(i, i+2+j+2)
+			i += 2 + j + 1
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,112 @@
+package conf
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// filePrefix reads a string from the file, like "file:/run/secrets/ss_pw".
+	filePrefix = "file:"
+)
+
+// fileKeys are the keys of the credentials read by filePrefix, the other strings like tags and paths are kept.
+var fileKeys = map[string]bool{
+	"password":    true,
+	"uuid":        true,
+	"key":         true,
+	"certificate": true,
+	"token":       true,
+}
+
+// interpolate replaces the strings of the object in place, a "${NAME}" is the environment variable
+// and a credential "file:/path" is the content of the file without the trailing newline.
+// "$${" is kept as "${". Every missing variable or file is reported at its json path.
+func interpolate(object map[string]interface{}) Problems {
+	var problems Problems
+	for k, v := range object {
+		object[k] = interpolateValue(k, k, v, &problems)
+	}
+
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems
+}
+
+// interpolateValue replaces the strings of v at the json path, the elements of an array are of the key of the array.
+func interpolateValue(path string, key string, v interface{}, problems *Problems) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			v[k] = interpolateValue(path+"."+k, k, e, problems)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = interpolateValue(fmt.Sprintf("%s[%d]", path, i), key, e, problems)
+		}
+	case string:
+		s, err := interpolateString(v, fileKeys[key])
+		if err != nil {
+			*problems = append(*problems, Problem{
+				Path: path,
+				Err:  err,
+			})
+			return v
+		}
+		return s
+	}
+	return v
+}
+
+func interpolateString(s string, file bool) (string, error) {
+	if file && strings.HasPrefix(s, filePrefix) {
+		name := strings.TrimPrefix(s, filePrefix)
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return "", newError("failed to read file %s", name).WithError(err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if !strings.Contains(s, "${") {
+		return s, nil
+	}
+
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			return "", newError("unclosed ${ in [%s]", s)
+		}
+
+		name := s[i+2 : i+end]
+		if len(name) == 0 {
+			return "", newError("empty env in [%s]", s)
+		}
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", newError("env %s is not set", name)
+		}
+
+		b.WriteString(s[:i])
+		b.WriteString(value)
+		s = s[i+end+1:]
+	}
+}
@@ -1,16 +1,20 @@
 package conf
 
 import (
+	"encoding/json"
 	"net/netip"
 	"strings"
 
 	dns_app "v2ray.com/core/app/dns"
+	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/app/proxyman/outbound"
 	router_app "v2ray.com/core/app/router"
 	"v2ray.com/core/common/geofile"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/protocol/mux"
 	router_common "v2ray.com/core/common/router"
+	"v2ray.com/core/common/session"
 	"v2ray.com/core/common/setting/loader"
 	"v2ray.com/core/proxy/block"
 	dns_proxy "v2ray.com/core/proxy/dns"
@@ -28,7 +32,6 @@ import (
 	"v2ray.com/core/transport/internet/tls"
 	tun_transport "v2ray.com/core/transport/internet/tun"
 	"v2ray.com/core/transport/internet/udp"
-	"v2ray.com/core/transport/internet/websocket"
 )
 
 const (
@@ -66,6 +69,23 @@ func Loads() error {
 		return err
 	}
 
+	problems := conf.Validate()
+	if err := problems.Err(); err != nil {
+		return err
+	}
+
+	if err := conf.LoadLog(); err != nil {
+		return err
+	}
+
+	for _, warning := range problems.Warnings() {
+		newError("%s", warning).AtWarning().Logging()
+	}
+
+	if err := conf.LoadStats(); err != nil {
+		return err
+	}
+
 	if err := conf.LoadRouter(); err != nil {
 		return err
 	}
@@ -86,121 +106,356 @@ func Loads() error {
 		return err
 	}
 
+	if err := conf.LoadAPI(); err != nil {
+		return err
+	}
+
+	setRunning(conf)
+
 	return nil
 }
 
-func (c config) LoadInbound() error {
+func (c config) LoadLog() error {
+	if err := loader.RegisterLog(loader.LogSetting{
+		Level:    c.Log.Level,
+		Format:   c.Log.Format,
+		Path:     c.Log.Path,
+		Rotation: buildLogRotationSetting(c.Log.Rotation),
+	}); err != nil {
+		return err
+	}
+
+	if c.Log.Access != nil {
+		if err := loader.RegisterAccessLog(loader.AccessLogSetting{
+			Format:   c.Log.Access.Format,
+			Path:     c.Log.Access.Path,
+			Rotation: buildLogRotationSetting(c.Log.Access.Rotation),
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func buildLogRotationSetting(c logRotationConfig) loader.LogRotationSetting {
+	return loader.LogRotationSetting{
+		MaxSize:    c.MaxSize,
+		MaxAge:     c.MaxAge,
+		MaxBackups: c.MaxBackups,
+	}
+}
+
+func (c config) LoadAPI() error {
+	if len(c.Api.Listen) == 0 {
+		return nil
+	}
+
+	return loader.RegisterAPI(loader.APISetting{
+		Listen:          c.Api.Listen,
+		Token:           c.Api.Token,
+		AddInboundFunc:  AddInbounds,
+		AddOutboundFunc: AddOutbounds,
+		BuildUserFunc:   BuildUser,
+		ReloadFunc:      Reload,
+		Locker:          &runningLock,
+	})
+}
+
+// BuildUser builds the user of the json, a vmess user has the uuid and a shadowsocks user has the security and password.
+func BuildUser(b []byte) (protocol.RequestUser, error) {
+	c := userConfig{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return protocol.RequestUser{}, newError("failed to unmarshal user").WithError(err)
+	}
+
+	user := protocol.RequestUser{
+		Level: c.Level,
+		Email: c.Email,
+	}
+
+	if len(c.UUID) > 0 {
+		vmessUser, err := loader.BuildVmessUser(loader.VmessUserSetting{
+			Security: loader.Vmess_Security_NONE,
+			UUID:     c.UUID,
+			Level:    c.Level,
+			Email:    c.Email,
+		})
+		if err != nil {
+			return protocol.RequestUser{}, err
+		}
+		user.Vmess = vmessUser
+	}
+
+	if len(c.Password) > 0 {
+		shadowsocksUser, err := loader.BuildShadowsocksUser(loader.ShadowsocksUserSetting{
+			Security: c.Security,
+			Password: c.Password,
+			Level:    c.Level,
+			Email:    c.Email,
+		})
+		if err != nil {
+			return protocol.RequestUser{}, err
+		}
+		user.Shadowsocks = shadowsocksUser
+	}
+
+	return user, nil
+}
+
+// AddInbounds loads the inbounds of the json like {"socks": [..]}, an existing tag is never replaced.
+// The api holds the runningLock while calling it, the inbounds are not in the running conf.
+func AddInbounds(b []byte) error {
+	c := config{}
+	if err := json.Unmarshal(b, &c.Inbounds); err != nil {
+		return newError("failed to unmarshal inbounds").WithError(err)
+	}
+
+	for _, tag := range c.inboundTags() {
+		exists := false
+		loader.RequireInstance().InboundManager.Range(func(handler proxyman.Inbound) bool {
+			exists = handler.Tag() == tag
+			return !exists
+		})
+		if exists {
+			return newError("inbound handler exists [%s]", tag)
+		}
+	}
+
+	return c.LoadInbound()
+}
+
+// AddOutbounds loads the outbounds of the json like {"freedom": [..]}, an existing tag is never replaced.
+// The api holds the runningLock while calling it, the outbounds are not in the running conf.
+func AddOutbounds(b []byte) error {
+	c := config{}
+	if err := json.Unmarshal(b, &c.Outbounds); err != nil {
+		return newError("failed to unmarshal outbounds").WithError(err)
+	}
+
+	for _, tag := range c.outboundTags() {
+		if _, ok := loader.RequireInstance().OutboundManager.Get(tag); ok {
+			return newError("outbound handler exists [%s]", tag)
+		}
+	}
+
+	return c.LoadOutbound()
+}
+
+func (c config) inboundTags() []string {
+	var tags []string
 	for _, v := range c.Inbounds.Dokodemo {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Inbounds.Http {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Inbounds.Shadowsocks {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Inbounds.Socks {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Inbounds.Tun {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Inbounds.Vmess {
+		tags = append(tags, v.Tag)
+	}
+	return tags
+}
+
+func (c config) outboundTags() []string {
+	var tags []string
+	for _, v := range c.Outbounds.Block {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Dns {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Freedom {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Http {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Shadowsocks {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Socks {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Tor {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Trojan {
+		tags = append(tags, v.Tag)
+	}
+	for _, v := range c.Outbounds.Vmess {
+		tags = append(tags, v.Tag)
+	}
+	return tags
+}
+
+func (c config) LoadStats() error {
+	loader.RegisterStats(loader.StatsSetting{
+		Inbound:  c.Stats.Inbound,
+		Outbound: c.Stats.Outbound,
+		User:     c.Stats.User,
+	})
+
+	return nil
+}
+
+// buildInbounds builds the settings of the inbound handlers, nothing listens until loader.NewInboundHandler.
+func (c config) buildInbounds() ([]loader.InboundHandlerSetting, error) {
+	var settings []loader.InboundHandlerSetting
+
+	// the servers built before a failure are closed
+	built := false
+	defer func() {
+		if !built {
+			closeServers(settings)
+		}
+	}()
+
+	for _, v := range c.Inbounds.Dokodemo {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		listenerFunc, err := buildStreamListener(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address, err := net.ParseAddress(network, v.Listen)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:          v.Tag,
 				Address:      address,
 				Server:       dokodemo.NewServer(),
-				ListenerFunc: tcp.Listen,
+				ListenerFunc: listenerFunc,
 				HubFunc:      udp.Listen,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
 	for _, v := range c.Inbounds.Http {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		listenerFunc, err := buildStreamListener(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address, err := net.ParseAddress(network, v.Listen)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:          v.Tag,
 				Address:      address,
 				Server:       http.NewServer(),
-				ListenerFunc: tcp.Listen,
+				ListenerFunc: listenerFunc,
 				HubFunc:      udp.Listen,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
 	for _, v := range c.Inbounds.Shadowsocks {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		listenerFunc, err := buildStreamListener(v.StreamSettings, v.Tcp, v.Websocket)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address, err := net.ParseAddress(network, v.Listen)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			user, err := loader.BuildShadowsocksUser(loader.ShadowsocksUserSetting{
 				Security: v.User.Security,
 				Password: v.User.Password,
+				Level:    v.User.Level,
+				Email:    v.User.Email,
 			})
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:     v.Tag,
 				Address: address,
 				Server: shadowsocks.NewServer(shadowsocks.ServerSetting{
 					User: user,
 				}),
-				ListenerFunc: func() internet.ListenerFunc {
-					if len(v.Websocket.Path) > 0 {
-						if len(v.Websocket.Tls.ServerName) > 0 {
-							return tls.Listen(tls.ListenSetting{
-								Config: loader.BuildTLSetting(loader.TLSetting{
-									ServerName:  v.Websocket.Tls.ServerName,
-									Certificate: v.Websocket.Tls.Certificate,
-									Key:         v.Websocket.Tls.Key,
-								}),
-							}, websocket.Listen(websocket.ListenSetting{
-								Path: v.Websocket.Path,
-							}, tcp.Listen))
-						}
-						return websocket.Listen(websocket.ListenSetting{
-							Path: v.Websocket.Path,
-						}, tcp.Listen)
-					}
-					if len(v.Tcp.Tls.ServerName) > 0 {
-						return tls.Listen(tls.ListenSetting{
-							Config: loader.BuildTLSetting(loader.TLSetting{
-								ServerName:  v.Tcp.Tls.ServerName,
-								Certificate: v.Tcp.Tls.Certificate,
-								Key:         v.Tcp.Tls.Key,
-							}),
-						}, tcp.Listen)
-					}
-					return tcp.Listen
-				}(),
-				HubFunc: udp.Listen,
+				ListenerFunc: listenerFunc,
+				HubFunc:      udp.Listen,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
 	for _, v := range c.Inbounds.Socks {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		listenerFunc, err := buildStreamListener(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address, err := net.ParseAddress(network, v.Listen)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:     v.Tag,
 				Address: address,
 				Server: socks.NewServer(socks.ServerSetting{
@@ -211,96 +466,114 @@ func (c config) LoadInbound() error {
 						return net.Address{}
 					}(),
 				}),
-				ListenerFunc: tcp.Listen,
+				ListenerFunc: listenerFunc,
 				HubFunc:      udp.Listen,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
 	for _, v := range c.Inbounds.Tun {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		// the stream of the tun is served over the connections of the tun device
+		stream := buildStreamSetting(v.StreamSettings, nil, nil)
+		stream.ListenerFunc = tun_transport.ListenTCP
+		listenerFunc, err := loader.BuildStreamListener(stream)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address := net.LocalhostTCPAddress
 			address.Network = net.Network(network)
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:          v.Tag,
 				Address:      address,
 				Server:       tun.NewServer(),
-				ListenerFunc: tun_transport.ListenTCP,
+				ListenerFunc: listenerFunc,
 				HubFunc:      tun_transport.ListenUDP,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
 	for _, v := range c.Inbounds.Vmess {
+		sniffing, err := buildSniffing(v.Sniffing)
+		if err != nil {
+			return nil, err
+		}
+
+		policy, err := buildPolicy(v.Policy)
+		if err != nil {
+			return nil, err
+		}
+
+		listenerFunc, err := buildStreamListener(v.StreamSettings, v.Tcp, v.Websocket)
+		if err != nil {
+			return nil, err
+		}
+
 		for _, network := range v.Network {
 			address, err := net.ParseAddress(network, v.Listen)
 			if err != nil {
-				return err
+				return nil, err
 			}
 
 			user, err := loader.BuildVmessUser(loader.VmessUserSetting{
 				Security: loader.Vmess_Security_NONE,
 				UUID:     v.User.UUID,
+				Level:    v.User.Level,
+				Email:    v.User.Email,
 			})
 			if err != nil {
-				return err
+				return nil, err
 			}
 
-			handler, err := loader.NewInboundHandler(loader.InboundHandlerSetting{
+			settings = append(settings, loader.InboundHandlerSetting{
 				Tag:     v.Tag,
 				Address: address,
 				Server: vmess.NewServer(vmess.ServerSetting{
 					User: user,
 				}),
-				ListenerFunc: func() internet.ListenerFunc {
-					if len(v.Websocket.Path) > 0 {
-						if len(v.Websocket.Tls.ServerName) > 0 {
-							return tls.Listen(tls.ListenSetting{
-								Config: loader.BuildTLSetting(loader.TLSetting{
-									ServerName:  v.Websocket.Tls.ServerName,
-									Certificate: v.Websocket.Tls.Certificate,
-									Key:         v.Websocket.Tls.Key,
-								}),
-							}, websocket.Listen(websocket.ListenSetting{
-								Path: v.Websocket.Path,
-							}, tcp.Listen))
-						}
-						return websocket.Listen(websocket.ListenSetting{
-							Path: v.Websocket.Path,
-						}, tcp.Listen)
-					}
-					if len(v.Tcp.Tls.ServerName) > 0 {
-						return tls.Listen(tls.ListenSetting{
-							Config: loader.BuildTLSetting(loader.TLSetting{
-								ServerName:  v.Tcp.Tls.ServerName,
-								Certificate: v.Tcp.Tls.Certificate,
-								Key:         v.Tcp.Tls.Key,
-							}),
-						}, tcp.Listen)
-					}
-					return tcp.Listen
-				}(),
-				HubFunc: udp.Listen,
+				ListenerFunc: listenerFunc,
+				HubFunc:      udp.Listen,
+				Sniffing:     sniffing,
+				Policy:       policy,
 			})
-			if err != nil {
-				return err
-			}
-
-			loader.RegisterInboundHandler(handler)
 		}
 	}
 
+	built = true
+	return settings, nil
+}
+
+func (c config) LoadInbound() error {
+	settings, err := c.buildInbounds()
+	if err != nil {
+		return err
+	}
+
+	handlers, err := listenInbounds(settings)
+	if err != nil {
+		return err
+	}
+
+	for _, handler := range handlers {
+		loader.RegisterInboundHandler(handler)
+	}
+
 	return nil
 }
 
@@ -311,7 +584,10 @@ func (c config) LoadDispatcher() error {
 	return nil
 }
 
-func (c config) LoadOutbound() error {
+// buildOutbounds builds the outbound handlers without registering them.
+func (c config) buildOutbounds() ([]proxyman.Outbound, error) {
+	var handlers []proxyman.Outbound
+
 	for _, v := range c.Outbounds.Block {
 		handler := outbound.NewOutbound(outbound.Setting{
 			Tag:         v.Tag,
@@ -320,7 +596,7 @@ func (c config) LoadOutbound() error {
 			UDPDialFunc: udp.Dial,
 		})
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Dns {
@@ -333,24 +609,34 @@ func (c config) LoadOutbound() error {
 			UDPDialFunc: udp.Dial,
 		})
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Freedom {
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
 		handler := outbound.NewOutbound(outbound.Setting{
 			Tag:         v.Tag,
 			Client:      freedom.NewClient(),
-			TCPDialFunc: tcp.Dial,
+			TCPDialFunc: dialTCPFunc,
 			UDPDialFunc: udp.Dial,
 		})
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Http {
 		address, err := net.ParseAddress(net.Network_TCP, v.Target)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
 		}
 
 		handler := outbound.NewOutbound(outbound.Setting{
@@ -358,7 +644,7 @@ func (c config) LoadOutbound() error {
 			Client: http.NewClient(http.ClientSetting{
 				Address: address,
 			}),
-			TCPDialFunc: tcp.Dial,
+			TCPDialFunc: dialTCPFunc,
 			UDPDialFunc: udp.Dial,
 		})
 
@@ -366,13 +652,13 @@ func (c config) LoadOutbound() error {
 			handler = mux.NewClient(handler)
 		}
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Shadowsocks {
 		address, err := net.ParseAddress(net.Network_TCP, v.Target)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		user, err := loader.BuildShadowsocksUser(loader.ShadowsocksUserSetting{
@@ -380,7 +666,12 @@ func (c config) LoadOutbound() error {
 			Password: v.User.Password,
 		})
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, v.Tcp, v.Websocket)
+		if err != nil {
+			return nil, err
 		}
 
 		handler := outbound.NewOutbound(outbound.Setting{
@@ -389,64 +680,27 @@ func (c config) LoadOutbound() error {
 				Address: address,
 				User:    user,
 			}),
-			TCPDialFunc: func() internet.DialTCPFunc {
-				if len(v.Websocket.Path) > 0 {
-					if len(v.Websocket.Tls.ServerName) > 0 {
-						return tls.Dial(tls.DialSetting{
-							Config: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, websocket.Dial(websocket.DialSetting{
-							Path: v.Websocket.Path,
-							TLSConfig: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, tcp.Dial))
-					}
-					return websocket.Dial(websocket.DialSetting{
-						Path: v.Websocket.Path,
-					}, tcp.Dial)
-				}
-				if len(v.Tcp.Tls.ServerName) > 0 {
-					return tls.Dial(tls.DialSetting{
-						Config: loader.BuildTLSetting(loader.TLSetting{
-							ServerName: v.Tcp.Tls.ServerName,
-						}),
-					}, tcp.Dial)
-				}
-				return tcp.Dial
-			}(),
-			TCPForwardDialFunc: func() outbound.ForwardDialTCPFunc {
-				if len(v.Forward.Tag) > 0 {
-					return loader.NewForwardDialTCPFunc(loader.OutboundForwardDialTCPFuncSetting{
-						Handlers: loader.RequireInstance().OutboundManager,
-						Tag:      v.Forward.Tag,
-						TLSConfig: func() tls.Config {
-							if len(v.Forward.Tls.ServerName) > 0 {
-								return loader.BuildTLSetting(loader.TLSetting{
-									ServerName: v.Forward.Tls.ServerName,
-								})
-							}
-							return tls.Config{}
-						}(),
-					})
-				}
-				return nil
-			}(),
-			UDPDialFunc: udp.Dial,
+			TCPDialFunc:        dialTCPFunc,
+			TCPForwardDialFunc: buildForwardDialer(v.Forward),
+			UDPDialFunc:        udp.Dial,
 		})
 
 		if v.Mux {
 			handler = mux.NewClient(handler)
 		}
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Socks {
 		address, err := net.ParseAddress(net.Network_TCP, v.Target)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, nil, nil)
+		if err != nil {
+			return nil, err
 		}
 
 		handler := outbound.NewOutbound(outbound.Setting{
@@ -454,7 +708,7 @@ func (c config) LoadOutbound() error {
 			Client: socks.NewClient(socks.ClientSetting{
 				Address: address,
 			}),
-			TCPDialFunc: tcp.Dial,
+			TCPDialFunc: dialTCPFunc,
 			UDPDialFunc: udp.Dial,
 		})
 
@@ -462,13 +716,21 @@ func (c config) LoadOutbound() error {
 			handler = mux.NewClient(handler)
 		}
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Tor {
-		dialer, err := loader.BuildTorClient()
+		// the stream of the tor is to the tor process
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, nil, nil)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		dialer, err := loader.BuildTorClient(loader.TorClientSetting{
+			DialTCPFunc: dialTCPFunc,
+		})
+		if err != nil {
+			return nil, err
 		}
 
 		handler := outbound.NewOutbound(outbound.Setting{
@@ -476,104 +738,51 @@ func (c config) LoadOutbound() error {
 			Client: tor.NewClient(tor.ClientSetting{
 				Dialer: dialer,
 			}),
-			TCPDialFunc: tcp.Dial,
-			TCPForwardDialFunc: func() outbound.ForwardDialTCPFunc {
-				if len(v.Forward.Tag) > 0 {
-					return loader.NewForwardDialTCPFunc(loader.OutboundForwardDialTCPFuncSetting{
-						Handlers: loader.RequireInstance().OutboundManager,
-						Tag:      v.Forward.Tag,
-						TLSConfig: func() tls.Config {
-							if len(v.Forward.Tls.ServerName) > 0 {
-								return loader.BuildTLSetting(loader.TLSetting{
-									ServerName: v.Forward.Tls.ServerName,
-								})
-							}
-							return tls.Config{}
-						}(),
-					})
-				}
-				return nil
-			}(),
-			UDPDialFunc: udp.Dial,
+			TCPDialFunc:        tcp.Dial,
+			TCPForwardDialFunc: buildForwardDialer(v.Forward),
+			UDPDialFunc:        udp.Dial,
 		})
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Trojan {
 		address, err := net.ParseAddress(net.Network_TCP, v.Target)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		user := loader.BuildTrojanUser(loader.TrojanUserSetting{
 			Password: v.User.Password,
 		})
 
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, v.Tcp, v.Websocket)
+		if err != nil {
+			return nil, err
+		}
+
 		handler := outbound.NewOutbound(outbound.Setting{
 			Tag: v.Tag,
 			Client: trojan.NewClient(trojan.ClientSetting{
 				Address: address,
 				User:    user,
 			}),
-			TCPDialFunc: func() internet.DialTCPFunc {
-				if len(v.Websocket.Path) > 0 {
-					if len(v.Websocket.Tls.ServerName) > 0 {
-						return tls.Dial(tls.DialSetting{
-							Config: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, websocket.Dial(websocket.DialSetting{
-							Path: v.Websocket.Path,
-							TLSConfig: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, tcp.Dial))
-					}
-					return websocket.Dial(websocket.DialSetting{
-						Path: v.Websocket.Path,
-					}, tcp.Dial)
-				}
-				if len(v.Tcp.Tls.ServerName) > 0 {
-					return tls.Dial(tls.DialSetting{
-						Config: loader.BuildTLSetting(loader.TLSetting{
-							ServerName: v.Tcp.Tls.ServerName,
-						}),
-					}, tcp.Dial)
-				}
-				return tcp.Dial
-			}(),
-			TCPForwardDialFunc: func() outbound.ForwardDialTCPFunc {
-				if len(v.Forward.Tag) > 0 {
-					return loader.NewForwardDialTCPFunc(loader.OutboundForwardDialTCPFuncSetting{
-						Handlers: loader.RequireInstance().OutboundManager,
-						Tag:      v.Forward.Tag,
-						TLSConfig: func() tls.Config {
-							if len(v.Forward.Tls.ServerName) > 0 {
-								return loader.BuildTLSetting(loader.TLSetting{
-									ServerName: v.Forward.Tls.ServerName,
-								})
-							}
-							return tls.Config{}
-						}(),
-					})
-				}
-				return nil
-			}(),
-			UDPDialFunc: udp.Dial,
+			TCPDialFunc:        dialTCPFunc,
+			TCPForwardDialFunc: buildForwardDialer(v.Forward),
+			UDPDialFunc:        udp.Dial,
 		})
 
 		if v.Mux {
 			handler = mux.NewClient(handler)
 		}
 
-		loader.RegisterOutboundHandler(handler)
+		handlers = append(handlers, handler)
 	}
 
 	for _, v := range c.Outbounds.Vmess {
 		address, err := net.ParseAddress(net.Network_TCP, v.Target)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		user, err := loader.BuildVmessUser(loader.VmessUserSetting{
@@ -581,7 +790,12 @@ func (c config) LoadOutbound() error {
 			UUID:     v.User.UUID,
 		})
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		dialTCPFunc, err := buildStreamDialer(v.StreamSettings, v.Tcp, v.Websocket)
+		if err != nil {
+			return nil, err
 		}
 
 		handler := outbound.NewOutbound(outbound.Setting{
@@ -590,57 +804,28 @@ func (c config) LoadOutbound() error {
 				Address: address,
 				User:    user,
 			}),
-			TCPDialFunc: func() internet.DialTCPFunc {
-				if len(v.Websocket.Path) > 0 {
-					if len(v.Websocket.Tls.ServerName) > 0 {
-						return tls.Dial(tls.DialSetting{
-							Config: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, websocket.Dial(websocket.DialSetting{
-							Path: v.Websocket.Path,
-							TLSConfig: loader.BuildTLSetting(loader.TLSetting{
-								ServerName: v.Websocket.Tls.ServerName,
-							}),
-						}, tcp.Dial))
-					}
-					return websocket.Dial(websocket.DialSetting{
-						Path: v.Websocket.Path,
-					}, tcp.Dial)
-				}
-				if len(v.Tcp.Tls.ServerName) > 0 {
-					return tls.Dial(tls.DialSetting{
-						Config: loader.BuildTLSetting(loader.TLSetting{
-							ServerName: v.Tcp.Tls.ServerName,
-						}),
-					}, tcp.Dial)
-				}
-				return tcp.Dial
-			}(),
-			TCPForwardDialFunc: func() outbound.ForwardDialTCPFunc {
-				if len(v.Forward.Tag) > 0 {
-					return loader.NewForwardDialTCPFunc(loader.OutboundForwardDialTCPFuncSetting{
-						Handlers: loader.RequireInstance().OutboundManager,
-						Tag:      v.Forward.Tag,
-						TLSConfig: func() tls.Config {
-							if len(v.Forward.Tls.ServerName) > 0 {
-								return loader.BuildTLSetting(loader.TLSetting{
-									ServerName: v.Forward.Tls.ServerName,
-								})
-							}
-							return tls.Config{}
-						}(),
-					})
-				}
-				return nil
-			}(),
-			UDPDialFunc: udp.Dial,
+			TCPDialFunc:        dialTCPFunc,
+			TCPForwardDialFunc: buildForwardDialer(v.Forward),
+			UDPDialFunc:        udp.Dial,
 		})
 
 		if v.Mux {
 			handler = mux.NewClient(handler)
 		}
 
+		handlers = append(handlers, handler)
+	}
+
+	return handlers, nil
+}
+
+func (c config) LoadOutbound() error {
+	handlers, err := c.buildOutbounds()
+	if err != nil {
+		return err
+	}
+
+	for _, handler := range handlers {
 		loader.RegisterOutboundHandler(handler)
 	}
 
@@ -714,7 +899,8 @@ func (c config) LoadDNS() error {
 	return nil
 }
 
-func (c config) LoadRouter() error {
+// buildRouter builds the matchers of the dns rules and the outbound rules without registering them.
+func (c config) buildRouter() (router_app.Matcher, router_app.Matcher, error) {
 	rules1 := make([]router_common.Rule, 0)
 	rules2 := make([]router_common.Rule, 0)
 
@@ -737,19 +923,14 @@ func (c config) LoadRouter() error {
 
 	for _, v := range c.Rules.Dns {
 		{
-			cc := make(router_common.Condition, 0)
-
-			for _, cond := range v.Condition {
-				sc, err := loader.ParseDefaultCondition(loader.ParseDefaultLookupConditionName, loader.DefaultConditionSetting{
-					Name:   cond.Name,
-					Length: cond.Length,
-					String: cond.String,
-				})
-				if err != nil {
-					return err
-				}
+			setting, err := buildConditionSettings(v.Condition, nil)
+			if err != nil {
+				return nil, nil, err
+			}
 
-				cc = append(cc, sc)
+			cc, err := loader.ParseDefaultConditions(loader.ParseDefaultLookupConditionName, setting)
+			if err != nil {
+				return nil, nil, err
 			}
 
 			rules1 = append(rules1, router_common.Rule{
@@ -761,51 +942,14 @@ func (c config) LoadRouter() error {
 
 	for _, v := range c.Rules.Outbound {
 		{
-			cc := make(router_common.Condition, 0)
-
-			for _, cond := range v.Condition {
-				str2 := make([]string, 0)
-				cidr := make([]netip.Prefix, 0)
-
-				for _, s := range cond.String {
-					if ip := strings.TrimPrefix(s, strCIDRip); len(ip) < len(s) {
-						ips, err := geofile.LoadIPCIDR([]string{ip})
-						if err != nil {
-							return err
-						}
-						cidr = append(cidr, ips...)
-					} else if ip := strings.TrimPrefix(s, strGeoip); len(ip) < len(s) {
-						ips0, err := geofile.LoadIPStr(ip)
-						if err != nil {
-							return err
-						}
-						ips, err := geofile.LoadIPCIDR(ips0)
-						if err != nil {
-							return err
-						}
-						cidr = append(cidr, ips...)
-					} else if site := strings.TrimPrefix(s, strGeosite); len(site) < len(s) {
-						sites, err := geofile.LoadSite(site)
-						if err != nil {
-							return err
-						}
-						str2 = append(str2, sites...)
-					} else {
-						str2 = append(str2, s)
-					}
-				}
-
-				sc, err := loader.ParseDefaultCondition(loader.ParseDefaultContentConditionName, loader.DefaultConditionSetting{
-					Name:   cond.Name,
-					Length: cond.Length,
-					String: str2,
-					CIDR:   cidr,
-				})
-				if err != nil {
-					return err
-				}
+			setting, err := buildConditionSettings(v.Condition, parseConditionString)
+			if err != nil {
+				return nil, nil, err
+			}
 
-				cc = append(cc, sc)
+			cc, err := loader.ParseDefaultConditions(loader.ParseDefaultContentConditionName, setting)
+			if err != nil {
+				return nil, nil, err
 			}
 
 			rules2 = append(rules2, router_common.Rule{
@@ -816,10 +960,245 @@ func (c config) LoadRouter() error {
 	}
 
 	m1 := router_app.NewMatcher(rules1...)
-	loader.RegisterNameserverMatcher(m1)
 
-	m2 := router_app.NewMatcher(rules2...)
+	domainStrategy, err := loader.ParseDomainStrategy(c.Rules.DomainStrategy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m2 := router_app.NewMatcherWithSetting(router_app.MatcherSetting{
+		DomainStrategy: domainStrategy,
+		LookupIPFunc:   loader.NewNameserverLookupIPFunc(),
+	}, rules2...)
+
+	return m1, m2, nil
+}
+
+func (c config) LoadRouter() error {
+	m1, m2, err := c.buildRouter()
+	if err != nil {
+		return err
+	}
+
+	loader.RegisterNameserverMatcher(m1)
 	loader.RegisterOutboundMatcher(m2)
 
 	return nil
 }
+
+type parseConditionStringFunc = func([]string) ([]string, []netip.Prefix, error)
+
+func buildConditionSettings(conds []ruleCondition, parseFunc parseConditionStringFunc) ([]loader.DefaultConditionSetting, error) {
+	settings := make([]loader.DefaultConditionSetting, 0, len(conds))
+
+	for _, cond := range conds {
+		setting, err := buildConditionSetting(cond, parseFunc)
+		if err != nil {
+			return nil, err
+		}
+
+		settings = append(settings, setting)
+	}
+
+	return settings, nil
+}
+
+func buildConditionSetting(cond ruleCondition, parseFunc parseConditionStringFunc) (loader.DefaultConditionSetting, error) {
+	setting := loader.DefaultConditionSetting{
+		Name:   cond.Name,
+		Length: cond.Length,
+		String: cond.String,
+		Not:    cond.Not,
+	}
+
+	for _, v := range cond.Schedule {
+		setting.Schedule = append(setting.Schedule, loader.ScheduleSetting{
+			Days:     v.Days,
+			Ranges:   v.Ranges,
+			TimeZone: v.TimeZone,
+		})
+	}
+
+	if parseFunc != nil {
+		str2, cidr, err := parseFunc(cond.String)
+		if err != nil {
+			return loader.DefaultConditionSetting{}, err
+		}
+
+		setting.String = str2
+		setting.CIDR = cidr
+	}
+
+	for _, v := range cond.Any {
+		any2, err := buildConditionSettings(v, parseFunc)
+		if err != nil {
+			return loader.DefaultConditionSetting{}, err
+		}
+
+		setting.Any = append(setting.Any, any2)
+	}
+
+	all2, err := buildConditionSettings(cond.All, parseFunc)
+	if err != nil {
+		return loader.DefaultConditionSetting{}, err
+	}
+	setting.All = all2
+
+	return setting, nil
+}
+
+func parseConditionString(str []string) ([]string, []netip.Prefix, error) {
+	str2 := make([]string, 0)
+	cidr := make([]netip.Prefix, 0)
+
+	for _, s := range str {
+		if ip := strings.TrimPrefix(s, strCIDRip); len(ip) < len(s) {
+			ips, err := geofile.LoadIPCIDR([]string{ip})
+			if err != nil {
+				return nil, nil, err
+			}
+			cidr = append(cidr, ips...)
+		} else if ip := strings.TrimPrefix(s, strGeoip); len(ip) < len(s) {
+			ips0, err := geofile.LoadIPStr(ip)
+			if err != nil {
+				return nil, nil, err
+			}
+			ips, err := geofile.LoadIPCIDR(ips0)
+			if err != nil {
+				return nil, nil, err
+			}
+			cidr = append(cidr, ips...)
+		} else if site := strings.TrimPrefix(s, strGeosite); len(site) < len(s) {
+			sites, err := geofile.LoadSite(site)
+			if err != nil {
+				return nil, nil, err
+			}
+			str2 = append(str2, sites...)
+		} else {
+			str2 = append(str2, s)
+		}
+	}
+
+	return str2, cidr, nil
+}
+
+// buildStreamSetting builds the streamSettings, the tcp and websocket of the old conf are used if it is absent.
+func buildStreamSetting(stream *streamConfig, legacyTcp *tcpConfig, legacyWebsocket *websocketConfig) loader.StreamSetting {
+	legacyTLS := func(c *tlsConfig) *loader.TLSetting {
+		if c == nil || len(c.ServerName) == 0 {
+			return nil
+		}
+		return buildTLSetting(c)
+	}
+
+	switch {
+	case stream != nil:
+		return loader.StreamSetting{
+			Network: stream.Network,
+			Path:    stream.Path,
+			Headers: stream.Headers,
+			TLS:     buildTLSetting(stream.Tls),
+			Sockopt: loader.SockoptSetting{
+				Mark:      stream.Sockopt.Mark,
+				Interface: stream.Sockopt.Interface,
+				KeepAlive: stream.Sockopt.KeepAlive,
+			},
+		}
+	case legacyWebsocket != nil && len(legacyWebsocket.Path) > 0:
+		return loader.StreamSetting{
+			Network: loader.Stream_Network_WEBSOCKET,
+			Path:    legacyWebsocket.Path,
+			TLS:     legacyTLS(legacyWebsocket.Tls),
+		}
+	case legacyTcp != nil:
+		return loader.StreamSetting{
+			Network: loader.Stream_Network_TCP,
+			TLS:     legacyTLS(legacyTcp.Tls),
+		}
+	default:
+		return loader.StreamSetting{}
+	}
+}
+
+func buildTLSetting(c *tlsConfig) *loader.TLSetting {
+	if c == nil {
+		return nil
+	}
+
+	return &loader.TLSetting{
+		ServerName:    c.ServerName,
+		Certificate:   c.Certificate,
+		Key:           c.Key,
+		AllowInsecure: c.AllowInsecure,
+		Alpn:          c.Alpn,
+	}
+}
+
+func buildStreamListener(stream *streamConfig, legacyTcp *tcpConfig, legacyWebsocket *websocketConfig) (internet.ListenerFunc, error) {
+	return loader.BuildStreamListener(buildStreamSetting(stream, legacyTcp, legacyWebsocket))
+}
+
+func buildStreamDialer(stream *streamConfig, legacyTcp *tcpConfig, legacyWebsocket *websocketConfig) (internet.DialTCPFunc, error) {
+	return loader.BuildStreamDialer(buildStreamSetting(stream, legacyTcp, legacyWebsocket))
+}
+
+// buildForwardDialer dials through the outbound of the forward tag, it is nil without the tag.
+func buildForwardDialer(forward forwardConfig) outbound.ForwardDialTCPFunc {
+	if len(forward.Tag) == 0 {
+		return nil
+	}
+
+	return loader.NewForwardDialTCPFunc(loader.OutboundForwardDialTCPFuncSetting{
+		Handlers: loader.RequireInstance().OutboundManager,
+		Tag:      forward.Tag,
+		TLSConfig: func() tls.Config {
+			if len(forward.Tls.ServerName) > 0 {
+				return loader.BuildTLSetting(loader.TLSetting{
+					ServerName: forward.Tls.ServerName,
+				})
+			}
+			return tls.Config{}
+		}(),
+	})
+}
+
+func buildSniffing(sniffing *sniffingConfig) (session.Sniffing, error) {
+	if sniffing == nil {
+		return session.DefaultSniffing(), nil
+	}
+
+	return loader.BuildSniffing(loader.SniffingSetting{
+		Enabled:         sniffing.Enabled,
+		DestOverride:    sniffing.DestOverride,
+		DomainsExcluded: sniffing.DomainsExcluded,
+		RouteOnly:       sniffing.RouteOnly,
+		Timeout:         sniffing.Timeout,
+	})
+}
+
+// buildPolicy returns the zero policy without the policy, so that the conf before the policy never closes a connection.
+func buildPolicy(policy *policyConfig) (session.Policy, error) {
+	if policy == nil {
+		return session.Policy{}, nil
+	}
+
+	return loader.BuildPolicy(buildPolicySetting(*policy))
+}
+
+func buildPolicySetting(policy policyConfig) loader.PolicySetting {
+	setting := loader.PolicySetting{
+		Handshake:    policy.Handshake,
+		ConnIdle:     policy.ConnIdle,
+		UplinkOnly:   policy.UplinkOnly,
+		DownlinkOnly: policy.DownlinkOnly,
+	}
+
+	if len(policy.Levels) > 0 {
+		setting.Levels = make(map[string]loader.PolicySetting, len(policy.Levels))
+		for level, v := range policy.Levels {
+			setting.Levels[level] = buildPolicySetting(v)
+		}
+	}
+
+	return setting
+}
@@ -0,0 +1,48 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// decodeObject decodes a json object keeping the numbers as they are.
+func decodeObject(b []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+
+	object := map[string]interface{}{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+// mergeObject merges the src into the dst, objects are merged, arrays are concatenated and the others are overridden.
+func mergeObject(dst, src map[string]interface{}) {
+	for k, v := range src {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if d, ok := dst[k].(map[string]interface{}); ok {
+				mergeObject(d, v)
+				continue
+			}
+		case []interface{}:
+			if d, ok := dst[k].([]interface{}); ok {
+				dst[k] = append(d, v...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// sortRules moves the rules of a higher priority ahead, the rules of the same priority keep their order.
+func (c *config) sortRules() {
+	sort.SliceStable(c.Rules.Dns, func(i, j int) bool {
+		return c.Rules.Dns[i].Priority > c.Rules.Dns[j].Priority
+	})
+	sort.SliceStable(c.Rules.Outbound, func(i, j int) bool {
+		return c.Rules.Outbound[i].Priority > c.Rules.Outbound[j].Priority
+	})
+}
@@ -0,0 +1,381 @@
+package conf
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+
+	"v2ray.com/core/app/proxyman"
+	"v2ray.com/core/app/proxyman/inbound"
+	"v2ray.com/core/common/io"
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/setting/loader"
+)
+
+var (
+	runningLock sync.Mutex
+	// running is the conf loaded last, the reload is diffed against it
+	running config
+)
+
+func setRunning(c config) {
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	running = c
+}
+
+// entry is an inbound or an outbound of the json, it is keyed by its protocol and tag.
+type entry struct {
+	protocol string
+	tag      string
+	network  []string
+	raw      json.RawMessage
+}
+
+// entries splits the inbounds or the outbounds like {"socks": [..], "vmess": [..]} into entries by their keys.
+// An entry without a tag is keyed by its json.
+func entries(v interface{}) (map[string]entry, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	protocols := make(map[string][]json.RawMessage)
+	if err := json.Unmarshal(b, &protocols); err != nil {
+		return nil, err
+	}
+
+	m := make(map[string]entry)
+	for protocol, raws := range protocols {
+		for _, raw := range raws {
+			var e struct {
+				Tag     string   `json:"tag"`
+				Network []string `json:"network"`
+			}
+			if err := json.Unmarshal(raw, &e); err != nil {
+				return nil, err
+			}
+
+			key := protocol + "/" + e.Tag
+			if len(e.Tag) == 0 {
+				key = protocol + "/" + string(raw)
+			}
+
+			m[key] = entry{
+				protocol: protocol,
+				tag:      e.Tag,
+				network:  e.Network,
+				raw:      raw,
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// buildEntries joins the entries into the inbounds or the outbounds.
+func buildEntries(entries []entry, v interface{}) error {
+	protocols := make(map[string][]json.RawMessage)
+	for _, e := range entries {
+		protocols[e.protocol] = append(protocols[e.protocol], e.raw)
+	}
+
+	b, err := json.Marshal(protocols)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// diffEntries returns the entries added or changed in the new, and the entries removed or changed in the old.
+func diffEntries(old, new map[string]entry) ([]entry, []entry) {
+	var added, removed []entry
+
+	for key, e := range new {
+		if o, ok := old[key]; !ok || string(o.raw) != string(e.raw) {
+			added = append(added, e)
+		}
+	}
+	for key, e := range old {
+		if n, ok := new[key]; !ok || string(n.raw) != string(e.raw) {
+			removed = append(removed, e)
+		}
+	}
+
+	sort.Slice(added, func(i, j int) bool {
+		return added[i].tag < added[j].tag
+	})
+	sort.Slice(removed, func(i, j int) bool {
+		return removed[i].tag < removed[j].tag
+	})
+
+	return added, removed
+}
+
+// Reload reads the conf again and applies it against the running one.
+// Only the inbounds and outbounds changed are replaced, so that the other tunnels are kept, and the rules are swapped.
+// The dns, log, api and stats are applied after a restart. Every handler and rule is built before the running ones
+// are touched, and the closed inbounds listen again if a new one fails, so that a failed reload changes nothing.
+// The handlers added by the api are kept and never replaced, the users added by the api to a changed inbound are dropped.
+func Reload() error {
+	c, err := unmarshal()
+	if err != nil {
+		return err
+	}
+
+	problems := c.Validate()
+	if err := problems.Err(); err != nil {
+		return err
+	}
+
+	runningLock.Lock()
+	defer runningLock.Unlock()
+
+	for _, section := range c.restartSections(running) {
+		newError("changed %s is applied after a restart", section).AtWarning().Logging()
+	}
+	// the running sections are kept, so that the rule of the hosts matches the running dns
+	c.Dns, c.Log, c.Api, c.Stats = running.Dns, running.Log, running.Api, running.Stats
+
+	oldOutbounds, err := entries(running.Outbounds)
+	if err != nil {
+		return err
+	}
+	newOutbounds, err := entries(c.Outbounds)
+	if err != nil {
+		return err
+	}
+	addedOutbounds, removedOutbounds := diffEntries(oldOutbounds, newOutbounds)
+
+	oldInbounds, err := entries(running.Inbounds)
+	if err != nil {
+		return err
+	}
+	newInbounds, err := entries(c.Inbounds)
+	if err != nil {
+		return err
+	}
+	addedInbounds, removedInbounds := diffEntries(oldInbounds, newInbounds)
+
+	if err := checkAddedByAPI(oldInbounds, addedInbounds, oldOutbounds, addedOutbounds); err != nil {
+		return err
+	}
+
+	added := config{}
+	if err := buildEntries(addedOutbounds, &added.Outbounds); err != nil {
+		return err
+	}
+	outbounds, err := added.buildOutbounds()
+	if err != nil {
+		return err
+	}
+
+	m1, m2, err := c.buildRouter()
+	if err != nil {
+		closeOutbounds(outbounds)
+		return err
+	}
+
+	added = config{}
+	if err := buildEntries(addedInbounds, &added.Inbounds); err != nil {
+		closeOutbounds(outbounds)
+		return err
+	}
+	inboundSettings, err := added.buildInbounds()
+	if err != nil {
+		closeOutbounds(outbounds)
+		return err
+	}
+
+	removed := config{}
+	if err := buildEntries(removedInbounds, &removed.Inbounds); err != nil {
+		closeOutbounds(outbounds)
+		closeServers(inboundSettings)
+		return err
+	}
+	rollbackSettings, err := removed.buildInbounds()
+	if err != nil {
+		closeOutbounds(outbounds)
+		closeServers(inboundSettings)
+		return err
+	}
+
+	// a changed inbound is closed before it listens again on the same address
+	closed := closeInbounds(removedInbounds)
+
+	inbounds, err := listenInbounds(inboundSettings)
+	if err != nil {
+		closeOutbounds(outbounds)
+
+		rollbacks, err2 := listenInbounds(rollbackSettings)
+		if err2 != nil {
+			newError("failed to listen the closed inbounds again").WithError(err2).AtError().Logging()
+			for _, key := range closed {
+				loader.RequireInstance().InboundManager.Delete(key)
+			}
+		}
+		for _, handler := range rollbacks {
+			loader.RegisterInboundHandler(handler)
+		}
+		return err
+	}
+
+	closeServers(rollbackSettings)
+
+	// the outbounds are replaced before the rules are swapped, and the removed ones are deleted after
+	var closedOutbounds []proxyman.Outbound
+	for _, handler := range outbounds {
+		if old, ok := loader.RequireInstance().OutboundManager.Get(handler.Tag()); ok {
+			closedOutbounds = append(closedOutbounds, old)
+		}
+		loader.RegisterOutboundHandler(handler)
+	}
+
+	loader.RegisterNameserverMatcher(m1)
+	loader.RegisterOutboundMatcher(m2)
+
+	replaced := make(map[string]bool, len(addedOutbounds))
+	for _, e := range addedOutbounds {
+		replaced[e.tag] = true
+	}
+	for _, e := range removedOutbounds {
+		if replaced[e.tag] {
+			continue
+		}
+		if old, ok := loader.RequireInstance().OutboundManager.Get(e.tag); ok {
+			closedOutbounds = append(closedOutbounds, old)
+			loader.RequireInstance().OutboundManager.Delete(e.tag)
+		}
+	}
+	closeOutbounds(closedOutbounds)
+
+	for _, key := range closed {
+		loader.RequireInstance().InboundManager.Delete(key)
+	}
+	for _, handler := range inbounds {
+		loader.RegisterInboundHandler(handler)
+	}
+
+	running = c
+
+	newError("reloaded, %d inbounds and %d outbounds added or changed, %d inbounds and %d outbounds removed or changed",
+		len(addedInbounds), len(addedOutbounds), len(removedInbounds), len(removedOutbounds)).AtInfo().Logging()
+	for _, warning := range problems.Warnings() {
+		newError("%s", warning).AtWarning().Logging()
+	}
+
+	return nil
+}
+
+// checkAddedByAPI fails if an added entry has the tag of a handler added by the api, which is not in the running conf.
+func checkAddedByAPI(oldInbounds map[string]entry, addedInbounds []entry, oldOutbounds map[string]entry, addedOutbounds []entry) error {
+	tags := make(map[string]bool)
+	for _, e := range oldInbounds {
+		tags[e.tag] = true
+	}
+	for _, e := range addedInbounds {
+		if len(e.tag) == 0 || tags[e.tag] {
+			continue
+		}
+		for _, network := range e.network {
+			if _, ok := loader.RequireInstance().InboundManager.Get(inbound.Key{
+				Tag:     e.tag,
+				Network: net.Network(network),
+			}); ok {
+				return newError("inbound handler added by the api exists [%s]", e.tag)
+			}
+		}
+	}
+
+	tags = make(map[string]bool)
+	for _, e := range oldOutbounds {
+		tags[e.tag] = true
+	}
+	for _, e := range addedOutbounds {
+		if len(e.tag) == 0 || tags[e.tag] {
+			continue
+		}
+		if _, ok := loader.RequireInstance().OutboundManager.Get(e.tag); ok {
+			return newError("outbound handler added by the api exists [%s]", e.tag)
+		}
+	}
+
+	return nil
+}
+
+// closeInbounds closes the handlers of the entries and returns their keys, they are still in the manager.
+func closeInbounds(entries []entry) []inbound.Key {
+	var keys []inbound.Key
+	for _, e := range entries {
+		for _, network := range e.network {
+			key := inbound.Key{
+				Tag:     e.tag,
+				Network: net.Network(network),
+			}
+			if handler, ok := loader.RequireInstance().InboundManager.Get(key); ok {
+				_ = handler.Close()
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// listenInbounds listens every inbound, the listened ones and the servers of the others are closed if any fails.
+func listenInbounds(settings []loader.InboundHandlerSetting) ([]proxyman.Inbound, error) {
+	handlers := make([]proxyman.Inbound, 0, len(settings))
+	for i, setting := range settings {
+		handler, err := loader.NewInboundHandler(setting)
+		if err != nil {
+			for _, h := range handlers {
+				_ = h.Close()
+			}
+			closeServers(settings[i:])
+			return nil, err
+		}
+		handlers = append(handlers, handler)
+	}
+	return handlers, nil
+}
+
+// closeServers closes the servers of the settings never listened, a vmess server runs its periodic tasks once built.
+func closeServers(settings []loader.InboundHandlerSetting) {
+	for _, setting := range settings {
+		if closer, ok := setting.Server.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	}
+}
+
+// closeOutbounds closes the replaced or removed outbounds, or the built ones of a failed reload.
+func closeOutbounds(handlers []proxyman.Outbound) {
+	for _, handler := range handlers {
+		if err := handler.Close(); err != nil {
+			newError("failed to close outbound handler [%s]", handler.Tag()).WithError(err).AtDebug().Logging()
+		}
+	}
+}
+
+// restartSections returns the sections changed but never reloaded.
+func (c config) restartSections(old config) []string {
+	sections := []struct {
+		name     string
+		old, new interface{}
+	}{
+		{"dns", old.Dns, c.Dns},
+		{"log", old.Log, c.Log},
+		{"api", old.Api, c.Api},
+		{"stats", old.Stats, c.Stats},
+	}
+
+	var changed []string
+	for _, section := range sections {
+		o, _ := json.Marshal(section.old)
+		n, _ := json.Marshal(section.new)
+		if string(o) != string(n) {
+			changed = append(changed, section.name)
+		}
+	}
+	return changed
+}
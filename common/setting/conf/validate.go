@@ -0,0 +1,496 @@
+package conf
+
+import (
+	"fmt"
+	"net/netip"
+	"runtime"
+	"sort"
+	"strings"
+
+	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/setting/loader"
+)
+
+// Problem is an invalid value of the conf at its json path, like "outbounds.vmess[2].user.uuid".
+// A warning is reported but never fails the loading.
+type Problem struct {
+	Path    string
+	Err     error
+	Warning bool
+}
+
+func (p Problem) Error() string {
+	if p.Warning {
+		return fmt.Sprintf("%s: warning: %v", p.Path, p.Err)
+	}
+	return fmt.Sprintf("%s: %v", p.Path, p.Err)
+}
+
+// Problems are all the problems of the conf, it is an error if any is not a warning.
+type Problems []Problem
+
+func (p Problems) Error() string {
+	s := make([]string, 0, len(p))
+	for _, problem := range p {
+		s = append(s, problem.Error())
+	}
+	return strings.Join(s, "\n")
+}
+
+// Err returns the problems if any is not a warning, or nil.
+func (p Problems) Err() error {
+	for _, problem := range p {
+		if !problem.Warning {
+			return p
+		}
+	}
+	return nil
+}
+
+// Warnings returns the warnings only.
+func (p Problems) Warnings() Problems {
+	var warnings Problems
+	for _, problem := range p {
+		if problem.Warning {
+			warnings = append(warnings, problem)
+		}
+	}
+	return warnings
+}
+
+type validator struct {
+	problems Problems
+
+	inboundTags  map[string]string
+	outboundTags map[string]string
+	// forwards are the forwards of the outbounds by their tags
+	forwards map[string]forward
+	// used are the outbound tags used by the rules and the forwards
+	used map[string]bool
+}
+
+type forward struct {
+	path string
+	tag  string
+}
+
+func (v *validator) error(path string, err error) {
+	v.problems = append(v.problems, Problem{
+		Path: path,
+		Err:  err,
+	})
+}
+
+func (v *validator) warn(path string, err error) {
+	v.problems = append(v.problems, Problem{
+		Path:    path,
+		Err:     err,
+		Warning: true,
+	})
+}
+
+// Validate reports every problem of the conf without loading it, so that no listener is started.
+func (c config) Validate() Problems {
+	v := &validator{
+		inboundTags:  make(map[string]string),
+		outboundTags: make(map[string]string),
+		forwards:     make(map[string]forward),
+		used:         make(map[string]bool),
+	}
+
+	c.validateLog(v)
+	c.validateAPI(v)
+	c.validateDNS(v)
+	c.validateInbounds(v)
+	c.validateOutbounds(v)
+	c.validateRules(v)
+	v.validateForwards()
+	v.validateUnused()
+
+	return v.problems
+}
+
+func (c config) validateLog(v *validator) {
+	if _, err := loader.ParseLogLevel(c.Log.Level); err != nil {
+		v.error("log.level", err)
+	}
+	if _, err := loader.ParseLogFormat(c.Log.Format); err != nil {
+		v.error("log.format", err)
+	}
+	if _, err := loader.BuildLogRotation(buildLogRotationSetting(c.Log.Rotation)); err != nil {
+		v.error("log.rotation", err)
+	}
+
+	if c.Log.Access != nil {
+		if _, err := loader.ParseAccessLogFormat(c.Log.Access.Format); err != nil {
+			v.error("log.access.format", err)
+		}
+		if _, err := loader.BuildLogRotation(buildLogRotationSetting(c.Log.Access.Rotation)); err != nil {
+			v.error("log.access.rotation", err)
+		}
+	}
+}
+
+func (c config) validateAPI(v *validator) {
+	if len(c.Api.Listen) > 0 && len(c.Api.Token) == 0 {
+		v.error("api.token", newError("no token"))
+	}
+}
+
+func (c config) validateDNS(v *validator) {
+	for i, fake := range c.Dns.Fake {
+		path := fmt.Sprintf("dns.fake[%d]", i)
+		if _, err := netip.ParsePrefix(fake.Cidr6); err != nil {
+			v.error(path+".cidr6", err)
+		}
+		if _, err := netip.ParsePrefix(fake.Cidr4); err != nil {
+			v.error(path+".cidr4", err)
+		}
+	}
+
+	for i, doh := range c.Dns.Doh {
+		path := fmt.Sprintf("dns.doh[%d]", i)
+		if len(doh.Tag) == 0 {
+			v.error(path+".tag", newError("no tag"))
+		}
+		if _, err := loader.ParseNameserverUrl(doh.Url); err != nil || len(doh.Url) == 0 {
+			v.error(path+".url", newError("invalid url [%s]", doh.Url).WithError(err))
+		}
+	}
+}
+
+func (c config) validateInbounds(v *validator) {
+	inbound := func(path, tag string, networks []string, listen *string, sniffing *sniffingConfig, policy *policyConfig) {
+		v.inboundTag(path, tag)
+
+		for i, network := range networks {
+			if network != net.Network_TCP && network != net.Network_UDP {
+				v.error(fmt.Sprintf("%s.network[%d]", path, i), newError("unknown network [%s]", network))
+				continue
+			}
+			if listen != nil {
+				if _, err := net.ParseAddress(network, *listen); err != nil {
+					v.error(path+".listen", newError("invalid listen [%s]", *listen).WithError(err))
+				}
+			}
+		}
+
+		if _, err := buildSniffing(sniffing); err != nil {
+			v.error(path+".sniffing", err)
+		}
+		if _, err := buildPolicy(policy); err != nil {
+			v.error(path+".policy", err)
+		}
+	}
+
+	for i, in := range c.Inbounds.Dokodemo {
+		path := fmt.Sprintf("inbounds.dokodemo[%d]", i)
+		inbound(path, in.Tag, in.Network, &in.Listen, in.Sniffing, in.Policy)
+		v.stream(path, in.StreamSettings, nil, nil, true)
+	}
+	for i, in := range c.Inbounds.Http {
+		path := fmt.Sprintf("inbounds.http[%d]", i)
+		inbound(path, in.Tag, in.Network, &in.Listen, in.Sniffing, in.Policy)
+		v.stream(path, in.StreamSettings, nil, nil, true)
+	}
+	for i, in := range c.Inbounds.Shadowsocks {
+		path := fmt.Sprintf("inbounds.shadowsocks[%d]", i)
+		inbound(path, in.Tag, in.Network, &in.Listen, in.Sniffing, in.Policy)
+
+		if _, err := loader.ParseShadowsocksUserSecurity(in.User.Security); err != nil {
+			v.error(path+".user.security", err)
+		}
+		v.stream(path, in.StreamSettings, in.Tcp, in.Websocket, true)
+	}
+	for i, in := range c.Inbounds.Socks {
+		path := fmt.Sprintf("inbounds.socks[%d]", i)
+		inbound(path, in.Tag, in.Network, &in.Listen, in.Sniffing, in.Policy)
+		v.stream(path, in.StreamSettings, nil, nil, true)
+	}
+	for i, in := range c.Inbounds.Tun {
+		path := fmt.Sprintf("inbounds.tun[%d]", i)
+		inbound(path, in.Tag, in.Network, nil, in.Sniffing, in.Policy)
+		v.stream(path, in.StreamSettings, nil, nil, true)
+		if in.StreamSettings != nil && in.StreamSettings.Sockopt != (sockoptConfig{}) {
+			v.error(path+".streamSettings.sockopt", newError("sockopt is unsupported by a tun"))
+		}
+	}
+	for i, in := range c.Inbounds.Vmess {
+		path := fmt.Sprintf("inbounds.vmess[%d]", i)
+		inbound(path, in.Tag, in.Network, &in.Listen, in.Sniffing, in.Policy)
+
+		if _, err := loader.ParseVmessUserUUID(in.User.UUID); err != nil {
+			v.error(path+".user.uuid", newError("invalid uuid [%s]", in.User.UUID).WithError(err))
+		}
+		v.stream(path, in.StreamSettings, in.Tcp, in.Websocket, true)
+	}
+}
+
+func (v *validator) inboundTag(path, tag string) {
+	if len(tag) == 0 {
+		return
+	}
+	if first, ok := v.inboundTags[tag]; ok {
+		v.error(path+".tag", newError("duplicate tag [%s] of %s", tag, first))
+		return
+	}
+	v.inboundTags[tag] = path
+}
+
+// stream reports the problems of the streamSettings, or of the tcp and websocket kept for the old conf.
+// The tls of a server must have the certificate and key.
+func (v *validator) stream(path string, stream *streamConfig, legacyTcp *tcpConfig, legacyWebsocket *websocketConfig, server bool) {
+	if legacyTcp != nil || legacyWebsocket != nil {
+		if stream != nil {
+			v.error(path+".streamSettings", newError("streamSettings and the old tcp or websocket are both set"))
+			return
+		}
+		v.warn(path, newError("tcp and websocket are deprecated, use streamSettings"))
+	}
+
+	setting := buildStreamSetting(stream, legacyTcp, legacyWebsocket)
+	path += ".streamSettings"
+
+	if _, err := loader.ParseStreamNetwork(setting.Network); err != nil {
+		v.error(path+".network", err)
+	}
+	if _, err := loader.BuildSockopt(setting.Sockopt); err != nil {
+		v.error(path+".sockopt.keepAlive", err)
+	}
+	if (setting.Sockopt.Mark != 0 || len(setting.Sockopt.Interface) > 0) && runtime.GOOS != "linux" {
+		v.error(path+".sockopt", newError("mark and interface are only supported on linux"))
+	}
+	if server && setting.TLS != nil && (len(setting.TLS.Certificate) == 0 || len(setting.TLS.Key) == 0) {
+		v.error(path+".tls", newError("certificate and key must be both set"))
+	}
+}
+
+func (c config) validateOutbounds(v *validator) {
+	target := func(path, target string) {
+		if _, err := net.ParseAddress(net.Network_TCP, target); err != nil {
+			v.error(path+".target", newError("invalid target [%s]", target).WithError(err))
+		}
+	}
+
+	for i, out := range c.Outbounds.Block {
+		v.outboundTag(fmt.Sprintf("outbounds.block[%d]", i), out.Tag)
+	}
+	for i, out := range c.Outbounds.Dns {
+		v.outboundTag(fmt.Sprintf("outbounds.dns[%d]", i), out.Tag)
+	}
+	for i, out := range c.Outbounds.Freedom {
+		path := fmt.Sprintf("outbounds.freedom[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, nil, nil, false)
+	}
+	for i, out := range c.Outbounds.Http {
+		path := fmt.Sprintf("outbounds.http[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, nil, nil, false)
+		target(path, out.Target)
+	}
+	for i, out := range c.Outbounds.Shadowsocks {
+		path := fmt.Sprintf("outbounds.shadowsocks[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, out.Tcp, out.Websocket, false)
+		target(path, out.Target)
+		v.forward(path, out.Tag, out.Forward.Tag)
+
+		if _, err := loader.ParseShadowsocksUserSecurity(out.User.Security); err != nil {
+			v.error(path+".user.security", err)
+		}
+	}
+	for i, out := range c.Outbounds.Socks {
+		path := fmt.Sprintf("outbounds.socks[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, nil, nil, false)
+		target(path, out.Target)
+	}
+	for i, out := range c.Outbounds.Tor {
+		path := fmt.Sprintf("outbounds.tor[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, nil, nil, false)
+		v.forward(path, out.Tag, out.Forward.Tag)
+	}
+	for i, out := range c.Outbounds.Trojan {
+		path := fmt.Sprintf("outbounds.trojan[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, out.Tcp, out.Websocket, false)
+		target(path, out.Target)
+		v.forward(path, out.Tag, out.Forward.Tag)
+	}
+	for i, out := range c.Outbounds.Vmess {
+		path := fmt.Sprintf("outbounds.vmess[%d]", i)
+		v.outboundTag(path, out.Tag)
+		v.stream(path, out.StreamSettings, out.Tcp, out.Websocket, false)
+		target(path, out.Target)
+		v.forward(path, out.Tag, out.Forward.Tag)
+
+		if _, err := loader.ParseVmessUserSecurity(out.User.Security); err != nil {
+			v.error(path+".user.security", err)
+		}
+		if _, err := loader.ParseVmessUserUUID(out.User.UUID); err != nil {
+			v.error(path+".user.uuid", newError("invalid uuid [%s]", out.User.UUID).WithError(err))
+		}
+	}
+}
+
+func (v *validator) outboundTag(path, tag string) {
+	if len(tag) == 0 {
+		v.error(path+".tag", newError("no tag"))
+		return
+	}
+	if first, ok := v.outboundTags[tag]; ok {
+		v.error(path+".tag", newError("duplicate tag [%s] of %s", tag, first))
+		return
+	}
+	v.outboundTags[tag] = path
+}
+
+func (v *validator) forward(path, tag, forwardTag string) {
+	if len(forwardTag) == 0 {
+		return
+	}
+	v.forwards[tag] = forward{
+		path: path + ".forward.tag",
+		tag:  forwardTag,
+	}
+	v.used[forwardTag] = true
+}
+
+// validateForwards reports the forwards to no outbound and the forwards back to the outbound itself.
+func (v *validator) validateForwards() {
+	tags := make([]string, 0, len(v.forwards))
+	for tag := range v.forwards {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		f := v.forwards[tag]
+		if _, ok := v.outboundTags[f.tag]; !ok {
+			v.error(f.path, newError("no outbound [%s]", f.tag))
+			continue
+		}
+
+		// a cycle not back to the tag is reported by the tags in it
+		chain := []string{tag}
+		seen := map[string]bool{tag: true}
+		for next := f.tag; ; {
+			chain = append(chain, next)
+			if next == tag {
+				v.error(f.path, newError("forward cycle [%s]", strings.Join(chain, " >> ")))
+				break
+			}
+			if seen[next] {
+				break
+			}
+			seen[next] = true
+
+			nextForward, ok := v.forwards[next]
+			if !ok {
+				break
+			}
+			next = nextForward.tag
+		}
+	}
+}
+
+func (c config) validateRules(v *validator) {
+	if _, err := loader.ParseDomainStrategy(c.Rules.DomainStrategy); err != nil {
+		v.error("rules.domainStrategy", err)
+	}
+
+	dnsTags := map[string]bool{
+		preservedHosts: true,
+		preservedDNS:   true,
+	}
+	for _, fake := range c.Dns.Fake {
+		dnsTags[fake.Tag] = true
+	}
+	for _, doh := range c.Dns.Doh {
+		dnsTags[doh.Tag] = true
+	}
+
+	for i, rule := range c.Rules.Dns {
+		path := fmt.Sprintf("rules.dns[%d]", i)
+		if !dnsTags[rule.OutboundTag] {
+			v.error(path+".outboundTag", newError("no dns [%s]", rule.OutboundTag))
+		}
+
+		setting, err := buildConditionSettings(rule.Condition, nil)
+		if err == nil {
+			_, err = loader.ParseDefaultConditions(loader.ParseDefaultLookupConditionName, setting)
+		}
+		if err != nil {
+			v.error(path+".condition", err)
+		}
+	}
+
+	for i, rule := range c.Rules.Outbound {
+		path := fmt.Sprintf("rules.outbound[%d]", i)
+		if _, ok := v.outboundTags[rule.OutboundTag]; !ok {
+			v.error(path+".outboundTag", newError("no outbound [%s]", rule.OutboundTag))
+		}
+		v.used[rule.OutboundTag] = true
+
+		for j, cond := range rule.Condition {
+			v.validateCIDR(fmt.Sprintf("%s.condition[%d]", path, j), cond)
+		}
+
+		setting, err := buildConditionSettings(rule.Condition, checkConditionString)
+		if err == nil {
+			_, err = loader.ParseDefaultConditions(loader.ParseDefaultContentConditionName, setting)
+		}
+		if err != nil {
+			v.error(path+".condition", err)
+		}
+	}
+}
+
+// validateCIDR reports the invalid "cidrip:" strings of the condition and its groups.
+func (v *validator) validateCIDR(path string, cond ruleCondition) {
+	for i, s := range cond.String {
+		if cidr := strings.TrimPrefix(s, strCIDRip); len(cidr) < len(s) {
+			if _, err := parseCIDR(cidr); err != nil {
+				v.error(fmt.Sprintf("%s.string[%d]", path, i), newError("invalid cidr [%s]", cidr).WithError(err))
+			}
+		}
+	}
+	for i, any := range cond.Any {
+		for j, cond := range any {
+			v.validateCIDR(fmt.Sprintf("%s.any[%d][%d]", path, i, j), cond)
+		}
+	}
+	for i, cond := range cond.All {
+		v.validateCIDR(fmt.Sprintf("%s.all[%d]", path, i), cond)
+	}
+}
+
+// checkConditionString keeps the strings as they are, so that no geo file is read.
+func checkConditionString(str []string) ([]string, []netip.Prefix, error) {
+	return str, nil, nil
+}
+
+// parseCIDR parses "10.0.0.0/8" or an ip as a single address prefix.
+func parseCIDR(s string) (netip.Prefix, error) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	return netip.ParsePrefix(s)
+}
+
+// validateUnused warns the outbounds never used by a rule or a forward.
+func (v *validator) validateUnused() {
+	tags := make([]string, 0, len(v.outboundTags))
+	for tag := range v.outboundTags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	for _, tag := range tags {
+		if !v.used[tag] {
+			v.warn(v.outboundTags[tag]+".tag", newError("unused outbound [%s]", tag))
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package loader
 
 import (
+	router_app "v2ray.com/core/app/router"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/session"
 )
@@ -18,3 +19,10 @@ func RegisterPreservedNameserver(tag string) {
 		})
 	}
 }
+
+// NewNameserverLookupIPFunc resolves by the nameserver registered at the time of lookup.
+func NewNameserverLookupIPFunc() router_app.LookupIPFunc {
+	return func(lookup session.Lookup) ([]net.IP, error) {
+		return RequireInstance().Nameserver.LookupIPCondition(net.LookupIPOption.Network.This(), lookup.Domain, lookup)
+	}
+}
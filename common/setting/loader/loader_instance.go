@@ -1,17 +1,24 @@
 package loader
 
 import (
+	"v2ray.com/core/app/accesslog"
+	"v2ray.com/core/app/api"
 	dns_app "v2ray.com/core/app/dns"
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/app/proxyman/inbound"
 	"v2ray.com/core/app/proxyman/outbound"
 	router_app "v2ray.com/core/app/router"
+	"v2ray.com/core/app/stats"
+	"v2ray.com/core/app/tracker"
+	"v2ray.com/core/common/log"
 )
 
 var (
 	localInstance = &Instance{
 		InboundManager:  inbound.NewManager(),
 		OutboundManager: outbound.NewManager(),
+		Stats:           stats.NewManager(stats.Setting{}),
+		Tracker:         tracker.NewTracker(tracker.Setting{}),
 	}
 )
 
@@ -25,8 +32,32 @@ type Instance struct {
 
 	OutboundManager outbound.Manager
 	OutboundMatcher router_app.Matcher
+
+	Stats     stats.Manager
+	Tracker   tracker.Tracker
+	AccessLog accesslog.Logger
+
+	API api.Server
 }
 
 func RequireInstance() *Instance {
 	return localInstance
 }
+
+// Close closes the api and the inbounds, then the logs.
+func (i *Instance) Close() error {
+	if i.API != nil {
+		_ = i.API.Close()
+	}
+
+	i.InboundManager.Range(func(handler proxyman.Inbound) bool {
+		_ = handler.Close()
+		return true
+	})
+
+	if i.AccessLog != nil {
+		_ = i.AccessLog.Close()
+	}
+
+	return log.Close()
+}
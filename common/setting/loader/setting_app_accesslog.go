@@ -0,0 +1,62 @@
+package loader
+
+import (
+	"v2ray.com/core/app/accesslog"
+	"v2ray.com/core/app/tracker"
+)
+
+const (
+	AccessLog_Format_TEXT = "text"
+	AccessLog_Format_JSON = "json"
+)
+
+type AccessLogSetting struct {
+	Format   string
+	Path     string
+	Rotation LogRotationSetting
+}
+
+// RegisterAccessLog must be called before the dispatcher is registered.
+func RegisterAccessLog(setting AccessLogSetting) error {
+	format, err := ParseAccessLogFormat(setting.Format)
+	if err != nil {
+		return err
+	}
+
+	rotation, err := BuildLogRotation(setting.Rotation)
+	if err != nil {
+		return err
+	}
+
+	logger, err := accesslog.NewLogger(accesslog.Setting{
+		Format:   format,
+		Path:     setting.Path,
+		Rotation: rotation,
+	})
+	if err != nil {
+		return err
+	}
+
+	localInstance.AccessLog = logger
+	localInstance.Tracker = tracker.NewTracker(tracker.Setting{
+		OnRemove: logger.Log,
+	})
+
+	if len(setting.Path) > 0 {
+		reopenOnSignal()
+	}
+
+	return nil
+}
+
+// ParseAccessLogFormat parses "text" or "json", empty is "text".
+func ParseAccessLogFormat(s string) (accesslog.Format, error) {
+	switch s {
+	case "", AccessLog_Format_TEXT:
+		return accesslog.Text, nil
+	case AccessLog_Format_JSON:
+		return accesslog.JSON, nil
+	default:
+		return 0, newError("unknown access log format %s", s)
+	}
+}
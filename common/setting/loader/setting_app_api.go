@@ -0,0 +1,43 @@
+package loader
+
+import (
+	"sync"
+
+	"v2ray.com/core/app/api"
+)
+
+type APISetting struct {
+	Listen string
+	Token  string
+
+	AddInboundFunc  api.AddFunc
+	AddOutboundFunc api.AddFunc
+	BuildUserFunc   api.BuildUserFunc
+	ReloadFunc      api.ReloadFunc
+	Locker          sync.Locker
+}
+
+// RegisterAPI must be called after the outbounds and the router are registered.
+func RegisterAPI(setting APISetting) error {
+	server, err := api.NewServer(api.Setting{
+		Listen:          setting.Listen,
+		Token:           setting.Token,
+		InboundManager:  localInstance.InboundManager,
+		OutboundManager: localInstance.OutboundManager,
+		OutboundMatcher: localInstance.OutboundMatcher,
+		Stats:           localInstance.Stats,
+		Tracker:         localInstance.Tracker,
+		AddInboundFunc:  setting.AddInboundFunc,
+		AddOutboundFunc: setting.AddOutboundFunc,
+		BuildUserFunc:   setting.BuildUserFunc,
+		ReloadFunc:      setting.ReloadFunc,
+		Locker:          setting.Locker,
+	})
+	if err != nil {
+		return err
+	}
+
+	localInstance.API = server
+
+	return nil
+}
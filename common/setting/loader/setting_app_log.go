@@ -0,0 +1,145 @@
+package loader
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"v2ray.com/core/common/log"
+)
+
+const (
+	Log_Format_TEXT = "text"
+	Log_Format_JSON = "json"
+)
+
+type LogSetting struct {
+	Level    string
+	Format   string
+	Path     string
+	Rotation LogRotationSetting
+}
+
+type LogRotationSetting struct {
+	// MaxSize is in megabytes.
+	MaxSize    int64
+	MaxAge     string
+	MaxBackups int
+}
+
+var (
+	reopenOnce sync.Once
+)
+
+func RegisterLog(setting LogSetting) error {
+	level, err := ParseLogLevel(setting.Level)
+	if err != nil {
+		return err
+	}
+
+	format, err := ParseLogFormat(setting.Format)
+	if err != nil {
+		return err
+	}
+
+	rotation, err := BuildLogRotation(setting.Rotation)
+	if err != nil {
+		return err
+	}
+
+	logger, err := log.NewLogger(log.Setting{
+		Level:    level,
+		Format:   format,
+		Path:     setting.Path,
+		Rotation: rotation,
+	})
+	if err != nil {
+		return newError("failed to open log %s", setting.Path).WithError(err)
+	}
+
+	log.RegisterAlternativeLogger(logger)
+
+	if len(setting.Path) > 0 {
+		reopenOnSignal()
+	}
+
+	return nil
+}
+
+// ParseLogLevel parses "debug", "info", "warning", "error" or "none", empty is "warning".
+func ParseLogLevel(s string) (log.Level, error) {
+	if len(s) == 0 {
+		return log.Warning, nil
+	}
+
+	level, ok := log.ParseLevel(s)
+	if !ok {
+		return 0, newError("unknown log level %s", s)
+	}
+	return level, nil
+}
+
+// ParseLogFormat parses "text" or "json", empty is "text".
+func ParseLogFormat(s string) (log.Format, error) {
+	switch s {
+	case "", Log_Format_TEXT:
+		return log.Text, nil
+	case Log_Format_JSON:
+		return log.JSON, nil
+	default:
+		return 0, newError("unknown log format %s", s)
+	}
+}
+
+func BuildLogRotation(setting LogRotationSetting) (log.Rotation, error) {
+	if setting.MaxSize < 0 {
+		return log.Rotation{}, newError("negative log max size %d", setting.MaxSize)
+	}
+	if setting.MaxBackups < 0 {
+		return log.Rotation{}, newError("negative log max backups %d", setting.MaxBackups)
+	}
+
+	rotation := log.Rotation{
+		MaxSize:    setting.MaxSize << 20,
+		MaxBackups: setting.MaxBackups,
+	}
+
+	if len(setting.MaxAge) > 0 {
+		maxAge, err := time.ParseDuration(setting.MaxAge)
+		if err != nil {
+			return log.Rotation{}, newError("unknown log max age %s", setting.MaxAge).WithError(err)
+		}
+		if maxAge < 0 {
+			return log.Rotation{}, newError("negative log max age %s", setting.MaxAge)
+		}
+		rotation.MaxAge = maxAge
+	}
+
+	return rotation, nil
+}
+
+// reopenOnSignal reopens the log files on SIGUSR1, as logrotate moves them.
+func reopenOnSignal() {
+	if len(reopenSignals) == 0 {
+		return
+	}
+
+	reopenOnce.Do(func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, reopenSignals...)
+
+		go func() {
+			for range c {
+				if err := log.Reopen(); err != nil {
+					newError("failed to reopen log").WithError(err).AtError().Logging()
+				}
+				if localInstance.AccessLog != nil {
+					if err := localInstance.AccessLog.Reopen(); err != nil {
+						newError("failed to reopen access log").WithError(err).AtError().Logging()
+					}
+				}
+			}
+		}()
+	})
+}
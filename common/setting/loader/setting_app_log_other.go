@@ -0,0 +1,10 @@
+//go:build !unix
+
+package loader
+
+import (
+	"os"
+)
+
+// reopenSignals are none, there is no SIGUSR1.
+var reopenSignals []os.Signal
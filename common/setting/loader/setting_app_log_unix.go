@@ -0,0 +1,11 @@
+//go:build unix
+
+package loader
+
+import (
+	"os"
+	"syscall"
+)
+
+// reopenSignals reopen the log files, the SIGHUP reloads the conf instead.
+var reopenSignals = []os.Signal{syscall.SIGUSR1}
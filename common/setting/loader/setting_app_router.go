@@ -2,45 +2,127 @@ package loader
 
 import (
 	"net/netip"
+	"strings"
+	"time"
 
 	router_app "v2ray.com/core/app/router"
 	router_common "v2ray.com/core/common/router"
 )
 
+// RegisterNameserverMatcher swaps the matcher once registered, the nameserver keeps the registered one.
 func RegisterNameserverMatcher(matcher router_app.Matcher) {
-	localInstance.NameserverMatcher = matcher
+	if m, ok := localInstance.NameserverMatcher.(*router_app.SwitchMatcher); ok {
+		m.Swap(matcher)
+		return
+	}
+	localInstance.NameserverMatcher = router_app.NewSwitchMatcher(matcher)
 }
 
+// RegisterOutboundMatcher swaps the matcher once registered, the dispatcher keeps the registered one.
 func RegisterOutboundMatcher(matcher router_app.Matcher) {
-	localInstance.OutboundMatcher = matcher
+	if m, ok := localInstance.OutboundMatcher.(*router_app.SwitchMatcher); ok {
+		m.Swap(matcher)
+		return
+	}
+	localInstance.OutboundMatcher = router_app.NewSwitchMatcher(matcher)
 }
 
 type DefaultConditionSetting struct {
-	Name   string
-	Length string
-	String []string
-	CIDR   []netip.Prefix
+	Name     string
+	Length   string
+	String   []string
+	CIDR     []netip.Prefix
+	Schedule []ScheduleSetting
+	Not      bool
+	Any      [][]DefaultConditionSetting
+	All      []DefaultConditionSetting
 }
 
 func ParseDefaultCondition(parseDefaultConditionNameFunc ParseDefaultConditionNameFunc, setting DefaultConditionSetting) (router_common.ConditionBody, error) {
+	if len(setting.Any) > 0 || len(setting.All) > 0 {
+		return ParseDefaultConditionGroup(parseDefaultConditionNameFunc, setting)
+	}
+
 	name, err := parseDefaultConditionNameFunc(setting.Name)
 	if err != nil {
 		return router_common.ConditionBody{}, err
 	}
 
-	length, err := ParseConditionLength(setting.Length)
+	if setting.Name == DefaultContentConditionName_Time && len(setting.Schedule) == 0 {
+		return router_common.ConditionBody{}, newError("condition %s without schedule", setting.Name)
+	}
+
+	if setting.Name != DefaultContentConditionName_Time && len(setting.Schedule) > 0 {
+		return router_common.ConditionBody{}, newError("condition %s with schedule", setting.Name)
+	}
+
+	// the length means nothing for the schedules
+	var length router_common.Length
+	if setting.Name != DefaultContentConditionName_Time || len(setting.Length) > 0 {
+		length, err = ParseConditionLength(setting.Length)
+		if err != nil {
+			return router_common.ConditionBody{}, err
+		}
+	}
+
+	schedule, err := ParseSchedules(setting.Schedule)
+	if err != nil {
+		return router_common.ConditionBody{}, err
+	}
+
+	return router_common.ConditionBody{
+		Name:     name,
+		Length:   length,
+		String:   setting.String,
+		CIDR:     setting.CIDR,
+		Schedule: schedule,
+		Not:      setting.Not,
+	}, nil
+}
+
+func ParseDefaultConditionGroup(parseDefaultConditionNameFunc ParseDefaultConditionNameFunc, setting DefaultConditionSetting) (router_common.ConditionBody, error) {
+	if len(setting.Name) > 0 || len(setting.String) > 0 || len(setting.CIDR) > 0 || len(setting.Schedule) > 0 {
+		return router_common.ConditionBody{}, newError("group with name %s", setting.Name)
+	}
+
+	anyOf := make([]router_common.Condition, 0, len(setting.Any))
+
+	for _, v := range setting.Any {
+		cc, err := ParseDefaultConditions(parseDefaultConditionNameFunc, v)
+		if err != nil {
+			return router_common.ConditionBody{}, err
+		}
+
+		anyOf = append(anyOf, cc)
+	}
+
+	allOf, err := ParseDefaultConditions(parseDefaultConditionNameFunc, setting.All)
 	if err != nil {
 		return router_common.ConditionBody{}, err
 	}
 
 	return router_common.ConditionBody{
-		Name:   name,
-		Length: length,
-		String: setting.String,
-		CIDR:   setting.CIDR,
+		Not: setting.Not,
+		Any: anyOf,
+		All: allOf,
 	}, nil
 }
 
+func ParseDefaultConditions(parseDefaultConditionNameFunc ParseDefaultConditionNameFunc, setting []DefaultConditionSetting) (router_common.Condition, error) {
+	cc := make(router_common.Condition, 0, len(setting))
+
+	for _, v := range setting {
+		sc, err := ParseDefaultCondition(parseDefaultConditionNameFunc, v)
+		if err != nil {
+			return nil, err
+		}
+
+		cc = append(cc, sc)
+	}
+
+	return cc, nil
+}
+
 type ParseDefaultConditionNameFunc = func(string) (router_common.Name, error)
 
 const (
@@ -52,6 +134,16 @@ const (
 	DefaultContentConditionName_SrcPort    = "srcPort"
 	DefaultContentConditionName_DstPort    = "dstPort"
 	DefaultContentConditionName_InboundTag = "inboundTag"
+
+	DefaultContentConditionName_ProcessUID  = "processUID"
+	DefaultContentConditionName_ProcessName = "processName"
+	DefaultContentConditionName_ProcessPath = "processPath"
+
+	DefaultContentConditionName_Time = "time"
+
+	DefaultContentConditionName_Protocol = "protocol"
+	DefaultContentConditionName_ALPN     = "alpn"
+	DefaultContentConditionName_Method   = "method"
 )
 
 const (
@@ -77,6 +169,20 @@ func ParseDefaultContentConditionName(s string) (router_common.Name, error) {
 		return router_app.DstPort, nil
 	case DefaultContentConditionName_InboundTag:
 		return router_app.InboundTag, nil
+	case DefaultContentConditionName_ProcessUID:
+		return router_app.ProcessUID, nil
+	case DefaultContentConditionName_ProcessName:
+		return router_app.ProcessName, nil
+	case DefaultContentConditionName_ProcessPath:
+		return router_app.ProcessPath, nil
+	case DefaultContentConditionName_Time:
+		return router_app.Time, nil
+	case DefaultContentConditionName_Protocol:
+		return router_app.Protocol, nil
+	case DefaultContentConditionName_ALPN:
+		return router_app.ALPN, nil
+	case DefaultContentConditionName_Method:
+		return router_app.Method, nil
 	default:
 		return 0, newError("unknown name %s", s)
 	}
@@ -93,6 +199,140 @@ func ParseDefaultLookupConditionName(s string) (router_common.Name, error) {
 	}
 }
 
+type ScheduleSetting struct {
+	Days     []string
+	Ranges   []string
+	TimeZone string
+}
+
+func ParseSchedules(setting []ScheduleSetting) ([]router_common.Schedule, error) {
+	schedules := make([]router_common.Schedule, 0, len(setting))
+
+	for _, v := range setting {
+		schedule, err := ParseSchedule(v)
+		if err != nil {
+			return nil, err
+		}
+
+		schedules = append(schedules, schedule)
+	}
+
+	return schedules, nil
+}
+
+func ParseSchedule(setting ScheduleSetting) (router_common.Schedule, error) {
+	schedule := router_common.Schedule{
+		Days:   make([]time.Weekday, 0, len(setting.Days)),
+		Ranges: make([]router_common.TimeRange, 0, len(setting.Ranges)),
+	}
+
+	for _, s := range setting.Days {
+		day, err := ParseWeekday(s)
+		if err != nil {
+			return router_common.Schedule{}, err
+		}
+
+		schedule.Days = append(schedule.Days, day)
+	}
+
+	for _, s := range setting.Ranges {
+		r, err := ParseTimeRange(s)
+		if err != nil {
+			return router_common.Schedule{}, err
+		}
+
+		schedule.Ranges = append(schedule.Ranges, r)
+	}
+
+	if len(setting.TimeZone) > 0 {
+		location, err := time.LoadLocation(setting.TimeZone)
+		if err != nil {
+			return router_common.Schedule{}, newError("unknown time zone %s", setting.TimeZone).WithError(err)
+		}
+
+		schedule.Location = location
+	}
+
+	return schedule, nil
+}
+
+// ParseTimeRange parses "09:00-17:00" or "22:00-02:00".
+func ParseTimeRange(s string) (router_common.TimeRange, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return router_common.TimeRange{}, newError("unknown time range %s", s)
+	}
+
+	fromOffset, err := ParseTimeOfDay(strings.TrimSpace(from))
+	if err != nil {
+		return router_common.TimeRange{}, err
+	}
+
+	toOffset, err := ParseTimeOfDay(strings.TrimSpace(to))
+	if err != nil {
+		return router_common.TimeRange{}, err
+	}
+
+	return router_common.TimeRange{
+		From: fromOffset,
+		To:   toOffset,
+	}, nil
+}
+
+// ParseTimeOfDay parses "17:30" into the offset since midnight, "24:00" is the end of a day.
+func ParseTimeOfDay(s string) (time.Duration, error) {
+	if s == "24:00" {
+		return 24 * time.Hour, nil
+	}
+
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, newError("unknown time of day %s", s).WithError(err)
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+func ParseWeekday(s string) (time.Weekday, error) {
+	switch strings.ToLower(s) {
+	case "sun", "sunday":
+		return time.Sunday, nil
+	case "mon", "monday":
+		return time.Monday, nil
+	case "tue", "tuesday":
+		return time.Tuesday, nil
+	case "wed", "wednesday":
+		return time.Wednesday, nil
+	case "thu", "thursday":
+		return time.Thursday, nil
+	case "fri", "friday":
+		return time.Friday, nil
+	case "sat", "saturday":
+		return time.Saturday, nil
+	default:
+		return 0, newError("unknown weekday %s", s)
+	}
+}
+
+const (
+	DomainStrategy_AsIs         = "AsIs"
+	DomainStrategy_IPIfNonMatch = "IPIfNonMatch"
+	DomainStrategy_IPOnDemand   = "IPOnDemand"
+)
+
+func ParseDomainStrategy(s string) (router_app.DomainStrategy, error) {
+	switch s {
+	case "", DomainStrategy_AsIs:
+		return router_app.AsIs, nil
+	case DomainStrategy_IPIfNonMatch:
+		return router_app.IPIfNonMatch, nil
+	case DomainStrategy_IPOnDemand:
+		return router_app.IPOnDemand, nil
+	default:
+		return 0, newError("unknown domain strategy %s", s)
+	}
+}
+
 const (
 	ConditionLength_Full  = "full"
 	ConditionLength_Sub   = "sub"
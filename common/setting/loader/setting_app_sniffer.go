@@ -0,0 +1,81 @@
+package loader
+
+import (
+	"time"
+
+	"v2ray.com/core/common/protocol/sniffer"
+	"v2ray.com/core/common/session"
+)
+
+type SniffingSetting struct {
+	Enabled         bool
+	DestOverride    []string
+	DomainsExcluded []string
+	RouteOnly       bool
+	Timeout         string
+}
+
+func BuildSniffing(setting SniffingSetting) (session.Sniffing, error) {
+	protocols := make([]sniffer.Protocol, 0, len(setting.DestOverride))
+
+	for _, s := range setting.DestOverride {
+		protocol, err := ParseSniffingProtocol(s)
+		if err != nil {
+			return session.Sniffing{}, err
+		}
+
+		protocols = append(protocols, protocol)
+	}
+
+	timeout, err := ParseSniffingTimeout(setting.Timeout)
+	if err != nil {
+		return session.Sniffing{}, err
+	}
+
+	return session.Sniffing{
+		Enabled:           setting.Enabled,
+		OverrideProtocols: protocols,
+		ExcludedDomains:   setting.DomainsExcluded,
+		RouteOnly:         setting.RouteOnly,
+		Timeout:           timeout,
+	}, nil
+}
+
+// ParseSniffingTimeout parses "300ms", empty waits the first payload until it comes.
+func ParseSniffingTimeout(s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, newError("unknown sniffing timeout %s", s).WithError(err)
+	}
+
+	return timeout, nil
+}
+
+const (
+	Sniffing_Protocol_FAKEDNS = "fakedns"
+	Sniffing_Protocol_HTTP    = "http"
+	Sniffing_Protocol_TLS     = "tls"
+	Sniffing_Protocol_QUIC    = "quic"
+	Sniffing_Protocol_H2C     = "h2c"
+)
+
+func ParseSniffingProtocol(s string) (sniffer.Protocol, error) {
+	switch s {
+	case Sniffing_Protocol_FAKEDNS:
+		return sniffer.Fake, nil
+	case Sniffing_Protocol_HTTP:
+		return sniffer.HTTP, nil
+	case Sniffing_Protocol_TLS:
+		return sniffer.TLS, nil
+	case Sniffing_Protocol_QUIC:
+		return sniffer.QUIC, nil
+	case Sniffing_Protocol_H2C:
+		return sniffer.H2C, nil
+	default:
+		return 0, newError("unknown sniffing protocol %s", s)
+	}
+}
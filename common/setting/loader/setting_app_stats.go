@@ -0,0 +1,20 @@
+package loader
+
+import (
+	"v2ray.com/core/app/stats"
+)
+
+type StatsSetting struct {
+	Inbound  bool
+	Outbound bool
+	User     bool
+}
+
+// RegisterStats must be called before the outbounds and the dispatcher are registered.
+func RegisterStats(setting StatsSetting) {
+	localInstance.Stats = stats.NewManager(stats.Setting{
+		Inbound:  setting.Inbound,
+		Outbound: setting.Outbound,
+		User:     setting.User,
+	})
+}
@@ -1,7 +1,6 @@
 package loader
 
 import (
-	"v2ray.com/core/common"
 	"v2ray.com/core/common/protocol/shadowsocks"
 )
 
@@ -9,6 +8,8 @@ type ShadowsocksUserSetting struct {
 	Security string
 	Password string
 	IvCheck  bool
+	Level    uint32
+	Email    string
 }
 
 func BuildShadowsocksUser(setting ShadowsocksUserSetting) (shadowsocks.User, error) {
@@ -21,6 +22,8 @@ func BuildShadowsocksUser(setting ShadowsocksUserSetting) (shadowsocks.User, err
 		Password: setting.Password,
 		Security: security,
 		IvCheck:  setting.IvCheck,
+		Level:    setting.Level,
+		Email:    setting.Email,
 	}, nil
 }
 
@@ -45,6 +48,6 @@ func ParseShadowsocksUserSecurity(s string) (shadowsocks.Security, error) {
 	case Shadowsocks_Security_NONE:
 		return shadowsocks.Security_NONE, nil
 	default:
-		return shadowsocks.Security_UNKNOWN, common.ErrUnknownNetwork
+		return shadowsocks.Security_UNKNOWN, newError("unknown shadowsocks security %s", s)
 	}
 }
@@ -2,6 +2,7 @@ package loader
 
 import (
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/transport/internet"
 
 	"github.com/cretz/bine/tor"
 	// _ "github.com/cretz/tor-static"
@@ -11,12 +12,22 @@ var (
 	localTor *tor.Tor
 )
 
-func BuildTorClient() (net.Dialer, error) {
+type TorClientSetting struct {
+	// DialTCPFunc dials the socks port of the tor process, the system dialer if nil.
+	DialTCPFunc internet.DialTCPFunc
+}
+
+func BuildTorClient(setting TorClientSetting) (net.Dialer, error) {
 	if err := buildTor(); err != nil {
 		return nil, err
 	}
 
-	dialer, err := localTor.Dialer(nil, nil)
+	conf := &tor.DialConf{}
+	if setting.DialTCPFunc != nil {
+		conf.Forward = torForwardDialer(setting.DialTCPFunc)
+	}
+
+	dialer, err := localTor.Dialer(nil, conf)
 	if err != nil {
 		return nil, err
 	}
@@ -24,6 +35,18 @@ func BuildTorClient() (net.Dialer, error) {
 	return net.NewDialer(dialer.Dial, net.LocalLookupIPFunc), nil
 }
 
+// torForwardDialer is the proxy.Dialer of the tor by the DialTCPFunc.
+type torForwardDialer internet.DialTCPFunc
+
+func (f torForwardDialer) Dial(network, address string) (net.Conn, error) {
+	dst, err := net.ParseAddress(network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	return f(net.Address{}, dst)
+}
+
 func buildTor() error {
 	t, err := tor.Start(nil, &tor.StartConf{
 		// ProcessCreator:         embedded.NewCreator(),
@@ -1,7 +1,6 @@
 package loader
 
 import (
-	"v2ray.com/core/common"
 	"v2ray.com/core/common/protocol/vmess"
 	"v2ray.com/core/common/uuid"
 )
@@ -9,6 +8,8 @@ import (
 type VmessUserSetting struct {
 	Security string
 	UUID     string
+	Level    uint32
+	Email    string
 }
 
 func BuildVmessUser(setting VmessUserSetting) (vmess.User, error) {
@@ -25,6 +26,8 @@ func BuildVmessUser(setting VmessUserSetting) (vmess.User, error) {
 	return vmess.User{
 		Security: security,
 		ID:       id,
+		Level:    setting.Level,
+		Email:    setting.Email,
 	}, nil
 }
 
@@ -68,6 +71,6 @@ func ParseVmessUserSecurity(s string) (vmess.Security, error) {
 	case Vmess_Security_ZERO:
 		return vmess.Security_ZERO, nil
 	default:
-		return vmess.Security_UNKNOWN, common.ErrUnknownNetwork
+		return vmess.Security_UNKNOWN, newError("unknown vmess security %s", s)
 	}
 }
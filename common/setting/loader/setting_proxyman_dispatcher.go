@@ -9,5 +9,5 @@ func RegisterMuxDispatcher() {
 }
 
 func RegisterDispatcher() {
-	localInstance.Dispatcher = dispatcher.NewDispatcher(localInstance.OutboundManager, localInstance.OutboundMatcher)
+	localInstance.Dispatcher = dispatcher.NewDispatcher(localInstance.OutboundManager, localInstance.OutboundMatcher, localInstance.Stats, localInstance.Tracker)
 }
@@ -3,14 +3,17 @@ package loader
 import (
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/app/proxyman/inbound"
-	"v2ray.com/core/common"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/session"
 	"v2ray.com/core/proxy"
 	"v2ray.com/core/transport/internet"
 )
 
 func RegisterInboundHandler(handler proxyman.Inbound) {
-	localInstance.InboundManager.Add(handler.Tag(), handler)
+	localInstance.InboundManager.Add(inbound.Key{
+		Tag:     handler.Tag(),
+		Network: handler.Network(),
+	}, handler)
 }
 
 type InboundHandlerSetting struct {
@@ -19,6 +22,8 @@ type InboundHandlerSetting struct {
 	Server       proxy.Server
 	ListenerFunc internet.ListenerFunc
 	HubFunc      internet.HubFunc
+	Sniffing     session.Sniffing
+	Policy       session.Policy
 }
 
 func NewInboundHandler(setting InboundHandlerSetting) (proxyman.Inbound, error) {
@@ -29,15 +34,19 @@ func NewInboundHandler(setting InboundHandlerSetting) (proxyman.Inbound, error)
 			Address:      setting.Address,
 			Server:       setting.Server,
 			ListenerFunc: setting.ListenerFunc,
+			Sniffing:     setting.Sniffing,
+			Policy:       setting.Policy,
 		})
 	case net.Network_UDP:
 		return inbound.NewUDPInbound(localInstance.Dispatcher, inbound.UDPSetting{
-			Tag:     setting.Tag,
-			Address: setting.Address,
-			Server:  setting.Server,
-			HubFunc: setting.HubFunc,
+			Tag:      setting.Tag,
+			Address:  setting.Address,
+			Server:   setting.Server,
+			HubFunc:  setting.HubFunc,
+			Sniffing: setting.Sniffing,
+			Policy:   setting.Policy,
 		})
 	default:
-		return nil, common.ErrUnknownNetwork
+		return nil, newError("unknown inbound network %s", setting.Address.Network)
 	}
 }
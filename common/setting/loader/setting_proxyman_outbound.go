@@ -12,7 +12,7 @@ import (
 )
 
 func RegisterOutboundHandler(handler proxyman.Outbound) {
-	localInstance.OutboundManager.Add(handler.Tag(), handler)
+	localInstance.OutboundManager.Add(handler.Tag(), outbound.NewStatsOutbound(handler, localInstance.Stats))
 }
 
 type OutboundForwardDialTCPFuncSetting struct {
@@ -0,0 +1,97 @@
+package loader
+
+import (
+	"strconv"
+	"time"
+
+	"v2ray.com/core/common/session"
+)
+
+type PolicySetting struct {
+	Handshake    string
+	ConnIdle     string
+	UplinkOnly   string
+	DownlinkOnly string
+	Levels       map[string]PolicySetting
+}
+
+// BuildPolicy overrides the default policy by the setting, a level policy overrides the built one.
+func BuildPolicy(setting PolicySetting) (session.Policy, error) {
+	policy, err := buildPolicy(session.DefaultPolicy(), setting)
+	if err != nil {
+		return session.Policy{}, err
+	}
+
+	if len(setting.Levels) == 0 {
+		return policy, nil
+	}
+
+	levels := make(map[uint32]session.Policy, len(setting.Levels))
+
+	for s, v := range setting.Levels {
+		level, err := ParsePolicyLevel(s)
+		if err != nil {
+			return session.Policy{}, err
+		}
+
+		levelPolicy, err := buildPolicy(policy, v)
+		if err != nil {
+			return session.Policy{}, err
+		}
+
+		levels[level] = levelPolicy
+	}
+
+	policy.Levels = levels
+
+	return policy, nil
+}
+
+func buildPolicy(policy session.Policy, setting PolicySetting) (session.Policy, error) {
+	timeouts := []struct {
+		s       string
+		timeout *time.Duration
+	}{
+		{setting.Handshake, &policy.Handshake},
+		{setting.ConnIdle, &policy.ConnIdle},
+		{setting.UplinkOnly, &policy.UplinkOnly},
+		{setting.DownlinkOnly, &policy.DownlinkOnly},
+	}
+
+	for _, v := range timeouts {
+		if len(v.s) == 0 {
+			continue
+		}
+
+		timeout, err := ParsePolicyTimeout(v.s)
+		if err != nil {
+			return session.Policy{}, err
+		}
+
+		*v.timeout = timeout
+	}
+
+	return policy, nil
+}
+
+// ParsePolicyTimeout parses "300s", "0" never expires.
+func ParsePolicyTimeout(s string) (time.Duration, error) {
+	timeout, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, newError("unknown policy timeout %s", s).WithError(err)
+	}
+	if timeout < 0 {
+		return 0, newError("negative policy timeout %s", s)
+	}
+
+	return timeout, nil
+}
+
+func ParsePolicyLevel(s string) (uint32, error) {
+	level, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0, newError("unknown policy level %s", s).WithError(err)
+	}
+
+	return uint32(level), nil
+}
@@ -0,0 +1,139 @@
+package loader
+
+import (
+	"time"
+
+	"v2ray.com/core/transport/internet"
+	"v2ray.com/core/transport/internet/tcp"
+	"v2ray.com/core/transport/internet/tls"
+	"v2ray.com/core/transport/internet/websocket"
+)
+
+const (
+	Stream_Network_TCP       = "tcp"
+	Stream_Network_WEBSOCKET = "websocket"
+)
+
+// StreamSetting is the transport of an inbound or an outbound, the tls is enabled if TLS is not nil.
+type StreamSetting struct {
+	Network string
+	// Path and Headers are of the websocket.
+	Path    string
+	Headers map[string]string
+	TLS     *TLSetting
+	Sockopt SockoptSetting
+	// ListenerFunc is the listener under the tls and the websocket, like the tun, it is the tcp with the sockopt if nil.
+	ListenerFunc internet.ListenerFunc
+}
+
+type SockoptSetting struct {
+	Mark      int
+	Interface string
+	KeepAlive string
+}
+
+// BuildStreamListener composes the listener of the stream, the websocket is served over the tls over the tcp.
+func BuildStreamListener(setting StreamSetting) (internet.ListenerFunc, error) {
+	network, err := ParseStreamNetwork(setting.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	sockopt, err := BuildSockopt(setting.Sockopt)
+	if err != nil {
+		return nil, err
+	}
+
+	listenerFunc := tcp.ListenSockopt(sockopt)
+	if setting.ListenerFunc != nil {
+		if sockopt != (internet.Sockopt{}) {
+			return nil, newError("sockopt is unsupported by the listener")
+		}
+		listenerFunc = setting.ListenerFunc
+	}
+
+	if setting.TLS != nil {
+		config := BuildTLSetting(*setting.TLS)
+		// the certificate is checked before listening, so that a bad one fails the building
+		if _, err := config.BuildTLSServer(); err != nil {
+			return nil, newError("invalid certificate").WithError(err)
+		}
+
+		listenerFunc = tls.Listen(tls.ListenSetting{
+			Config: config,
+		}, listenerFunc)
+	}
+
+	if network == Stream_Network_WEBSOCKET {
+		listenerFunc = websocket.Listen(websocket.ListenSetting{
+			Path: setting.Path,
+		}, listenerFunc)
+	}
+
+	return listenerFunc, nil
+}
+
+// BuildStreamDialer composes the dialer of the stream, the websocket dials wss itself with the tls.
+func BuildStreamDialer(setting StreamSetting) (internet.DialTCPFunc, error) {
+	network, err := ParseStreamNetwork(setting.Network)
+	if err != nil {
+		return nil, err
+	}
+
+	sockopt, err := BuildSockopt(setting.Sockopt)
+	if err != nil {
+		return nil, err
+	}
+
+	dialTCPFunc := tcp.DialSockopt(sockopt)
+
+	var config *tls.Config
+	if setting.TLS != nil {
+		c := BuildTLSetting(*setting.TLS)
+		config = &c
+	}
+
+	switch {
+	case network == Stream_Network_WEBSOCKET:
+		dialTCPFunc = websocket.Dial(websocket.DialSetting{
+			Path:      setting.Path,
+			Headers:   setting.Headers,
+			TLSConfig: config,
+		}, dialTCPFunc)
+	case config != nil:
+		dialTCPFunc = tls.Dial(tls.DialSetting{
+			Config: *config,
+		}, dialTCPFunc)
+	}
+
+	return dialTCPFunc, nil
+}
+
+// ParseStreamNetwork parses "tcp" or "websocket", empty is "tcp".
+func ParseStreamNetwork(s string) (string, error) {
+	switch s {
+	case "", Stream_Network_TCP:
+		return Stream_Network_TCP, nil
+	case Stream_Network_WEBSOCKET:
+		return Stream_Network_WEBSOCKET, nil
+	default:
+		return "", newError("unknown stream network %s", s)
+	}
+}
+
+func BuildSockopt(setting SockoptSetting) (internet.Sockopt, error) {
+	sockopt := internet.Sockopt{
+		Mark:      setting.Mark,
+		Interface: setting.Interface,
+	}
+
+	if len(setting.KeepAlive) > 0 {
+		keepAlive, err := time.ParseDuration(setting.KeepAlive)
+		if err != nil {
+			return internet.Sockopt{}, newError("unknown keep alive %s", setting.KeepAlive).WithError(err)
+		}
+		sockopt.KeepAlive = keepAlive
+	}
+
+	return sockopt, nil
+}
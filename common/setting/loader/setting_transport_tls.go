@@ -7,6 +7,8 @@ import (
 type TLSetting struct {
 	ServerName       string
 	Certificate, Key string
+	AllowInsecure    bool
+	Alpn             []string
 }
 
 func BuildTLSetting(setting TLSetting) tls.Config {
@@ -14,7 +16,9 @@ func BuildTLSetting(setting TLSetting) tls.Config {
 		ServerName: setting.ServerName,
 		Certificate: tls.Certificate{
 			Certificate: []byte(setting.Certificate),
-			Key:         []byte(setting.ServerName),
+			Key:         []byte(setting.Key),
 		},
+		AllowInsecure: setting.AllowInsecure,
+		NextProtos:    setting.Alpn,
 	}
 }
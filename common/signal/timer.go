@@ -0,0 +1,114 @@
+package signal
+
+import (
+	"sync"
+	"time"
+)
+
+// ActivityTimer calls its function once no activity is updated in a whole timeout.
+// A timeout not above zero never expires.
+type ActivityTimer interface {
+	Update()
+	SetTimeout(time.Duration)
+	Close() error
+}
+
+type activityTimer struct {
+	updated chan struct{}
+	timeout chan time.Duration
+	done    Done
+	once    sync.Once
+
+	onTimeout func()
+}
+
+// CancelAfterInactivity returns a running ActivityTimer, onTimeout is called at most once.
+func CancelAfterInactivity(onTimeout func(), timeout time.Duration) ActivityTimer {
+	t := &activityTimer{
+		updated:   make(chan struct{}, 1),
+		timeout:   make(chan time.Duration),
+		done:      NewDone(),
+		onTimeout: onTimeout,
+	}
+
+	go t.run(timeout)
+
+	return t
+}
+
+// Update marks an activity, this method never blocks.
+func (t *activityTimer) Update() {
+	select {
+	case t.updated <- struct{}{}:
+	default:
+	}
+}
+
+// SetTimeout replaces the timeout, the activities before are forgotten.
+func (t *activityTimer) SetTimeout(timeout time.Duration) {
+	select {
+	case t.timeout <- timeout:
+	case <-t.done.Wait():
+	}
+}
+
+// Close stops the timer without calling onTimeout.
+func (t *activityTimer) Close() error {
+	t.once.Do(func() {
+		_ = t.done.Close()
+	})
+
+	return nil
+}
+
+func (t *activityTimer) run(timeout time.Duration) {
+	var ticker *time.Ticker
+	var tick <-chan time.Time
+
+	reset := func(timeout time.Duration) {
+		if ticker != nil {
+			ticker.Stop()
+			ticker, tick = nil, nil
+		}
+
+		select {
+		case <-t.updated:
+		default:
+		}
+
+		if timeout > 0 {
+			ticker = time.NewTicker(timeout)
+			tick = ticker.C
+		}
+	}
+
+	reset(timeout)
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-tick:
+			select {
+			case <-t.updated:
+			default:
+				t.expire()
+				return
+			}
+		case timeout := <-t.timeout:
+			reset(timeout)
+		case <-t.done.Wait():
+			return
+		}
+	}
+}
+
+func (t *activityTimer) expire() {
+	t.once.Do(func() {
+		_ = t.done.Close()
+		t.onTimeout()
+	})
+}
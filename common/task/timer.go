@@ -41,8 +41,9 @@ func (t *timer) Period(fn TimerFunc, period time.Duration) {
 		select {
 		case <-t0.C:
 			fn()
+			t0.Reset(period)
 		case <-t.done:
-			break
+			return
 		}
 	}
 }
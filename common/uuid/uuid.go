@@ -34,10 +34,14 @@ func (u UUID) Equals(another UUID) bool {
 	return bytes.Equal(u.Bytes(), another.Bytes())
 }
 
-// New creates a UUID with random value.
+// New creates a version 4 UUID with random value.
 func New() (UUID, error) {
 	var uuid UUID
-	_, _ = rand.Read(uuid.Bytes())
+	if _, err := rand.Read(uuid[:]); err != nil {
+		return UUID{}, newError("failed to read random bytes").WithError(err)
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x40
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
 	return uuid, nil
 }
 
@@ -59,7 +63,7 @@ func ParseString(str string) (UUID, error) {
 
 	text := []byte(str)
 	if len(text) < 32 {
-		return uuid, newError("invalid UUID %d", str)
+		return uuid, newError("invalid UUID %s", str)
 	}
 
 	for _, byteGroup := range byteGroups {
@@ -3,6 +3,7 @@ module v2ray.com/core
 go 1.19
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/cretz/bine v0.2.0
 	github.com/gorilla/websocket v1.5.0
 	github.com/quic-go/quic-go v0.33.0
@@ -11,6 +12,7 @@ require (
 	golang.org/x/crypto v0.7.0
 	golang.org/x/net v0.8.0
 	golang.org/x/sys v0.6.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require github.com/dgryski/go-metro v0.0.0-20200812162917-85c65e2d0165 // indirect
@@ -0,0 +1,11 @@
+package main
+
+import (
+	"os"
+
+	"v2ray.com/core/cmd"
+)
+
+func main() {
+	os.Exit(cmd.Execute(os.Args[1:]))
+}
@@ -59,7 +59,7 @@ func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher p
 		return err
 	}
 
-	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 	requestDone := func() error {
 		defer func() {
@@ -110,13 +110,13 @@ func (s *server) handleUDPPayload(content session.Content, conn net.Conn, dispat
 				return dst
 			}()
 
-			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 			if err := udpServer.Dispatch(udp.DispatchSetting{
 				Content: content,
 				Address: dst,
 			}, buffer.MultiBuffer{payload}); err != nil {
-				newError("failed to dispatch UDP output").WithError(err).AtDebug().Logging()
+				newError("failed to dispatch UDP output").WithError(err).WithSession(content).AtDebug().Logging()
 			}
 		}
 	}
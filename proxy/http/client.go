@@ -9,6 +9,7 @@ import (
 	"v2ray.com/core/common/buffer"
 	"v2ray.com/core/common/bufio"
 	"v2ray.com/core/common/bytespool"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/session"
 	"v2ray.com/core/common/task"
@@ -67,7 +68,7 @@ func (c *client) Process(content session.Content, address net.Address, link tran
 
 	if err := func() error {
 		mb, err := buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload).ReadMultiBuffer()
-		if err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+		if err != nil && !errors.Is(err, buffer.ErrReadTimeout) {
 			return newError("failed to read first payload").WithError(err)
 		}
 
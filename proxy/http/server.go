@@ -43,7 +43,7 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 		return err
 	}
 
-	newError("receiving request [%s] [%s] [%s]", conn.RemoteAddr().String(), request.Method, request.URL.String()).AtInfo().Logging()
+	newError("receiving request [%s] [%s] [%s]", conn.RemoteAddr().String(), request.Method, request.URL.String()).WithSession(content).AtInfo().Logging()
 
 handle:
 	if request.Method == http.MethodConnect {
@@ -160,7 +160,7 @@ func handlePlainHTTP(content session.Content, dst net.Address, conn net.Conn, di
 				result = nil
 			}
 		} else {
-			newError("failed to read response from %s", request.URL.Host).WithError(err).AtDebug().Logging()
+			newError("failed to read response from %s", request.URL.Host).WithError(err).WithSession(content).AtDebug().Logging()
 
 			response = &http.Response{
 				Status:        "Service Unavailable",
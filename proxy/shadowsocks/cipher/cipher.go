@@ -21,8 +21,15 @@ type Cipher interface {
 	IsAEAD() bool
 	EncodePacket(key []byte, b *buffer.Buffer) error
 	DecodePacket(key []byte, b *buffer.Buffer) error
+	// StreamHeaderSize is the size of the iv and the first sealed chunk size of a stream.
+	StreamHeaderSize() int32
+	// VerifyStream returns true if the stream header is sealed by the key.
+	VerifyStream(key []byte, header []byte) bool
 }
 
+// aeadOverhead is the tag size of all the aead ciphers.
+const aeadOverhead = 16
+
 type aeadCipher struct {
 	KeyBytes        int32
 	IVBytes         int32
@@ -68,6 +75,22 @@ func (c *aeadCipher) NewDecryptionReader(key []byte, iv []byte, reader buffer.Bu
 	}, protocol.TransferTypeStream, nil), nil
 }
 
+func (c *aeadCipher) StreamHeaderSize() int32 {
+	return c.IVBytes + 2 + aeadOverhead
+}
+
+func (c *aeadCipher) VerifyStream(key []byte, header []byte) bool {
+	if int32(len(header)) < c.StreamHeaderSize() {
+		return false
+	}
+
+	auth := c.createAuthenticator(key, header[:c.IVBytes])
+	sealed := header[c.IVBytes:c.StreamHeaderSize()]
+
+	_, err := auth.Open(nil, sealed)
+	return err == nil
+}
+
 func (c *aeadCipher) EncodePacket(key []byte, b *buffer.Buffer) error {
 	ivLen := int(c.IVBytes)
 	payloadLen := b.Len()
@@ -116,6 +139,14 @@ func (noneCipher) NewEncryptionWriter(_ []byte, _ []byte, writer buffer.Writer)
 	return writer, nil
 }
 
+func (noneCipher) StreamHeaderSize() int32 {
+	return 0
+}
+
+func (noneCipher) VerifyStream(_ []byte, _ []byte) bool {
+	return true
+}
+
 func (noneCipher) EncodePacket(_ []byte, _ *buffer.Buffer) error {
 	return nil
 }
@@ -1,14 +1,20 @@
 package shadowsocks
 
 import (
+	"strings"
+	"sync"
+
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/protocol/shadowsocks"
 	"v2ray.com/core/common/session"
 	"v2ray.com/core/common/task"
 	"v2ray.com/core/proxy"
+	"v2ray.com/core/proxy/shadowsocks/cipher"
 	"v2ray.com/core/transport/internet/udp"
 )
 
@@ -17,12 +23,22 @@ type ServerSetting struct {
 }
 
 type server struct {
-	user shadowsocks.User
+	sync.RWMutex
+	// users are built once added, so that the keys are derived once rather than by every connection
+	users []cipher.User
 }
 
 func NewServer(setting ServerSetting) proxy.Server {
+	// a user of an unknown security keeps no cipher, it fails reading the request
+	user, err := cipher.BuildUser(setting.User)
+	if err != nil {
+		user = cipher.User{
+			User: setting.User,
+		}
+	}
+
 	return &server{
-		user: setting.User,
+		users: []cipher.User{user},
 	}
 }
 
@@ -44,11 +60,18 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher proxyman.Dispatcher) error {
 	connWriter, connReader := buffer.NewBufferedWriter(buffer.NewAllToBytesWriter(conn)), buffer.NewBufferedReader(buffer.NewIOReader(conn))
 
-	requestHeader, bodyReader, err := ReadTCPSession(s.user, connReader)
+	user, connReader, err := s.matchTCPUser(content, connReader)
 	if err != nil {
 		return newError("failed to read request").WithError(err)
 	}
 
+	requestHeader, bodyReader, err := ReadTCPSession(user, connReader)
+	if err != nil {
+		return newError("failed to read request").WithError(err)
+	}
+
+	s.setUser(content, user)
+
 	dst := requestHeader.Address.AsAddress(requestHeader.Command.Shadowsocks.Network())
 
 	link, err := dispatcher.Dispatch(content, dst)
@@ -56,7 +79,7 @@ func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher p
 		return err
 	}
 
-	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 	requestDone := func() error {
 		defer func() {
@@ -80,7 +103,7 @@ func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher p
 			return err
 		} */
 
-		if err := buffer.Copy(responseWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+		if err := buffer.Copy(responseWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && !errors.Is(err, buffer.ErrReadTimeout) {
 			return newError("failed to write first payload").WithError(err)
 		}
 
@@ -125,24 +148,183 @@ func (s *server) handleUDPPayload(content session.Content, conn net.Conn, dispat
 		}
 
 		for _, payload := range mb {
-			requestHeader, err := DecodeUDPPacket(s.user, payload)
+			requestHeader, payload, err := s.decodeUDPPacket(payload)
 			if err != nil {
-				newError("failed to parse UDP requestHeader").WithError(err).AtDebug().Logging()
+				newError("failed to parse UDP requestHeader").WithError(err).WithSession(content).AtDebug().Logging()
 				payload.Release()
 				continue
 			}
 
+			s.setUser(content, requestHeader.User.Shadowsocks)
+
 			dst := requestHeader.Address.AsAddress(requestHeader.Command.Shadowsocks.Network())
 
-			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 			if err := udpServer.Dispatch(udp.DispatchSetting{
 				Content:       content,
 				Address:       dst,
 				RequestHeader: requestHeader,
 			}, buffer.MultiBuffer{payload}); err != nil {
-				newError("failed to dispatch UDP output").WithError(err).AtDebug().Logging()
+				newError("failed to dispatch UDP output").WithError(err).WithSession(content).AtDebug().Logging()
 			}
 		}
 	}
 }
+
+func (s *server) setUser(content session.Content, user shadowsocks.User) {
+	ib, _ := content.GetInbound()
+	ib.Level = user.Level
+	ib.Email = user.Email
+	content.SetInbound(ib)
+}
+
+func (s *server) getUsers() []cipher.User {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.users
+}
+
+// matchTCPUser finds the user sealing the stream header, the header is read again from the returned reader.
+// The first user is returned if none matches, so that the stream is drained by it like a wrong password of a single user,
+// and an active prober never tells a multi-user inbound by the way it closes.
+func (s *server) matchTCPUser(content session.Content, reader buffer.BufferedReader) (shadowsocks.User, buffer.BufferedReader, error) {
+	users := s.getUsers()
+	if len(users) == 1 {
+		return users[0].User, reader, nil
+	}
+
+	size := int32(0)
+	for _, c := range users {
+		if c.Cipher == nil {
+			continue
+		}
+		if n := c.Cipher.StreamHeaderSize(); n > size {
+			size = n
+		}
+	}
+
+	header := buffer.New()
+	if _, err := header.ReadFullFrom(reader, int(size)); err != nil {
+		header.Release()
+		return shadowsocks.User{}, nil, newError("failed to read stream header").WithError(err)
+	}
+
+	reader = buffer.NewBufferedReader(&prefixReader{
+		prefix: buffer.MultiBuffer{header},
+		reader: reader,
+	})
+
+	for _, c := range users {
+		if c.Cipher != nil && c.Cipher.VerifyStream(c.Key, header.Bytes()) {
+			return c.User, reader, nil
+		}
+	}
+
+	newError("no user matches the stream header, draining it by the first user").WithSession(content).AtDebug().Logging()
+
+	return users[0].User, reader, nil
+}
+
+// decodeUDPPacket decodes the packet by the user sealing it, the decoded packet is returned.
+func (s *server) decodeUDPPacket(payload *buffer.Buffer) (protocol.RequestHeader, *buffer.Buffer, error) {
+	users := s.getUsers()
+	if len(users) == 1 {
+		requestHeader, err := DecodeUDPPacket(users[0].User, payload)
+		return requestHeader, payload, err
+	}
+
+	// a failed decoding clears the packet, every user decodes a copy
+	for _, user := range users {
+		packet := buffer.New()
+		_, _ = packet.Write(payload.Bytes())
+
+		requestHeader, err := DecodeUDPPacket(user.User, packet)
+		if err != nil {
+			packet.Release()
+			continue
+		}
+
+		payload.Release()
+		return requestHeader, packet, nil
+	}
+
+	return protocol.RequestHeader{}, payload, newError("no user decodes the packet")
+}
+
+func (s *server) AddUser(user protocol.RequestUser) error {
+	if len(user.Email) == 0 {
+		return newError("email is required")
+	}
+	if user.Shadowsocks.Security == shadowsocks.Security_UNKNOWN {
+		return newError("shadowsocks security is required [%s]", user.Email)
+	}
+
+	u := user.Shadowsocks
+	u.Level = user.Level
+	u.Email = user.Email
+
+	built, err := cipher.BuildUser(u)
+	if err != nil {
+		return newError("failed to build user [%s]", u.Email).WithError(err)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	for _, v := range s.users {
+		if strings.EqualFold(v.User.Email, u.Email) {
+			return newError("user exists [%s]", u.Email)
+		}
+		// a stream of the none security is never distinguished
+		if v.User.Security == shadowsocks.Security_NONE || u.Security == shadowsocks.Security_NONE {
+			return newError("none security supports a single user [%s]", u.Email)
+		}
+	}
+
+	users := make([]cipher.User, 0, len(s.users)+1)
+	users = append(users, s.users...)
+	s.users = append(users, built)
+
+	return nil
+}
+
+// RemoveUser removes the user of the email, the last user is never removed.
+func (s *server) RemoveUser(email string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for i, v := range s.users {
+		if len(email) == 0 || !strings.EqualFold(v.User.Email, email) {
+			continue
+		}
+
+		if len(s.users) == 1 {
+			return newError("the last user is never removed [%s]", email)
+		}
+
+		users := make([]cipher.User, 0, len(s.users)-1)
+		users = append(users, s.users[:i]...)
+		s.users = append(users, s.users[i+1:]...)
+
+		return nil
+	}
+
+	return newError("user not found [%s]", email)
+}
+
+// prefixReader reads the prefix before the reader.
+type prefixReader struct {
+	prefix buffer.MultiBuffer
+	reader buffer.Reader
+}
+
+func (r *prefixReader) ReadMultiBuffer() (buffer.MultiBuffer, error) {
+	if mb := r.prefix; mb != nil {
+		r.prefix = nil
+		return mb, nil
+	}
+
+	return r.reader.ReadMultiBuffer()
+}
@@ -72,7 +72,7 @@ func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher p
 				return err
 			}
 
-			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 			requestDone := func() error {
 				defer func() {
@@ -133,21 +133,21 @@ func (s *server) handleUDPPayload(content session.Content, conn net.Conn, dispat
 		for _, payload := range mb {
 			requestHeader, err := DecodeUDPPacket(payload)
 			if err != nil {
-				newError("failed to parse UDP requestHeader").WithError(err).AtDebug().Logging()
+				newError("failed to parse UDP requestHeader").WithError(err).WithSession(content).AtDebug().Logging()
 				payload.Release()
 				continue
 			}
 
 			dst := requestHeader.Address.AsAddress(requestHeader.Command.Socks.Network())
 
-			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 			if err := udpServer.Dispatch(udp.DispatchSetting{
 				Content:       content,
 				Address:       dst,
 				RequestHeader: requestHeader,
 			}, buffer.MultiBuffer{payload}); err != nil {
-				newError("failed to dispatch UDP output").WithError(err).AtDebug().Logging()
+				newError("failed to dispatch UDP output").WithError(err).WithSession(content).AtDebug().Logging()
 			}
 		}
 	}
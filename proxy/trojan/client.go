@@ -5,6 +5,7 @@ import (
 
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol/trojan"
 	"v2ray.com/core/common/session"
@@ -45,7 +46,7 @@ func (c *client) Process(content session.Content, address net.Address, link tran
 				return newError("failed to write first header").WithError(err)
 			}
 
-			if err := buffer.Copy(connWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+			if err := buffer.Copy(connWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && !errors.Is(err, buffer.ErrReadTimeout) {
 				return newError("failed to write first payload").WithError(err)
 			}
 
@@ -78,7 +79,7 @@ func (c *client) Process(content session.Content, address net.Address, link tran
 				return newError("failed to write first header").WithError(err)
 			}
 
-			if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+			if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && !errors.Is(err, buffer.ErrReadTimeout) {
 				return newError("failed to write first payload").WithError(err)
 			}
 
@@ -52,7 +52,7 @@ func (s *server) processTCP(content session.Content, conn net.Conn, dispatcher p
 		return err
 	}
 
-	newError("receiving request [%s] [%s]", conn.LocalAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+	newError("receiving request [%s] [%s]", conn.LocalAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 	requestDone := func() error {
 		defer func() {
@@ -105,13 +105,13 @@ func (s *server) handleUDPPayload(content session.Content, conn net.Conn, dispat
 		for _, payload := range mb {
 			dst := net.AddressFromAddr(conn.RemoteAddr())
 
-			newError("receiving request [%s] [%s]", conn.LocalAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+			newError("receiving request [%s] [%s]", conn.LocalAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 			if err := udpServer.Dispatch(udp.DispatchSetting{
 				Content: content,
 				Address: dst,
 			}, buffer.MultiBuffer{payload}); err != nil {
-				newError("failed to dispatch UDP output").WithError(err).AtDebug().Logging()
+				newError("failed to dispatch UDP output").WithError(err).WithSession(content).AtDebug().Logging()
 			}
 		}
 	}
@@ -8,6 +8,7 @@ import (
 
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/protocol/vmess"
@@ -61,7 +62,7 @@ func (c *client) Process(content session.Content, address net.Address, link tran
 				return newError("failed to start encoding").WithError(err)
 			}
 
-			if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+			if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && !errors.Is(err, buffer.ErrReadTimeout) {
 				return newError("failed to write first payload").WithError(err)
 			}
 
@@ -17,6 +17,7 @@ import (
 	"v2ray.com/core/common/buffer"
 	"v2ray.com/core/common/crypto"
 	"v2ray.com/core/common/drain"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/io"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/protocol/vmess"
@@ -180,7 +181,7 @@ func (s *ServerSession) DecodeRequestHeader(reader io.Reader) (protocol.RequestH
 	case errorAEAD == vmessaead.ErrNotFound:
 		userLegacy, timestamp, valid, userValidationError := s.userValidator.Get(buf.Bytes())
 		if !valid || userValidationError != nil {
-			return protocol.RequestHeader{}, drainConnection(newError("invalid user").WithError(userValidationError))
+			return protocol.RequestHeader{}, drainConnection(newError("invalid user").WithError(errors.ErrAuth).WithError(userValidationError))
 		}
 		if s.isAEADForced {
 			return protocol.RequestHeader{}, drainConnection(newError("invalid user: VMessAEAD is enforced and a non VMessAEAD connection is received. You can still disable this security feature with environment variable v2ray.vmess.aead.forced = false . You will not be able to enable legacy header workaround in the future."))
@@ -198,7 +199,7 @@ func (s *ServerSession) DecodeRequestHeader(reader io.Reader) (protocol.RequestH
 		decryptor = crypto.NewCryptionReader(aesStream, reader)
 
 	default:
-		return protocol.RequestHeader{}, drainConnection(newError("invalid user").WithError(errorAEAD))
+		return protocol.RequestHeader{}, drainConnection(newError("invalid user").WithError(errors.ErrAuth).WithError(errorAEAD))
 	}
 
 	drainer.AcknowledgeReceive(buf.Len())
@@ -1,9 +1,12 @@
 package vmess
 
 import (
+	"sync"
+
 	"v2ray.com/core/app/proxyman"
 	"v2ray.com/core/common"
 	"v2ray.com/core/common/buffer"
+	"v2ray.com/core/common/errors"
 	"v2ray.com/core/common/net"
 	"v2ray.com/core/common/protocol"
 	"v2ray.com/core/common/protocol/vmess"
@@ -24,6 +27,8 @@ type ServerSetting struct {
 }
 
 type server struct {
+	// usersLock serializes adding and removing users
+	usersLock      sync.Mutex
 	clients        *validator.TimedUserValidator
 	sessionHistory *encoding.SessionHistory
 }
@@ -71,6 +76,8 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 		return newError("invalid request").WithError(err)
 	}
 
+	s.setUser(content, requestHeader.User.Vmess)
+
 	dst := requestHeader.Address.AsAddress(requestHeader.Command.Vmess.Network())
 
 	link, err := dispatcher.Dispatch(content, dst)
@@ -78,7 +85,7 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 		return err
 	}
 
-	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).AtInfo().Logging()
+	newError("receiving request [%s] [%s]", conn.RemoteAddr().String(), dst.NetworkAndDomainPreferredAddress()).WithSession(content).AtInfo().Logging()
 
 	requestDone := func() error {
 		defer func() {
@@ -113,7 +120,7 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 			return err
 		} */
 
-		if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && buffer.CauseReadError(err) != buffer.ErrReadTimeout {
+		if err := buffer.Copy(bodyWriter, buffer.NewTimeoutReader(link.Reader, timeoutFirstPayload)); err != nil && buffer.IsReadError(err) && !errors.Is(err, buffer.ErrReadTimeout) {
 			return newError("failed to write first payload").WithError(err)
 		}
 
@@ -137,7 +144,45 @@ func (s *server) Process(content session.Content, conn net.Conn, dispatcher prox
 	return nil
 }
 
-// Close TODO call this
+func (s *server) setUser(content session.Content, user vmess.User) {
+	ib, _ := content.GetInbound()
+	ib.Level = user.Level
+	ib.Email = user.Email
+	content.SetInbound(ib)
+}
+
+func (s *server) AddUser(user protocol.RequestUser) error {
+	s.usersLock.Lock()
+	defer s.usersLock.Unlock()
+
+	if len(user.Email) == 0 {
+		return newError("email is required")
+	}
+	if user.Vmess.ID == (vmess.ID{}) {
+		return newError("vmess id is required [%s]", user.Email)
+	}
+	if s.clients.Has(user.Email) {
+		return newError("user exists [%s]", user.Email)
+	}
+
+	u := user.Vmess
+	u.Level = user.Level
+	u.Email = user.Email
+
+	return s.clients.Add(u)
+}
+
+func (s *server) RemoveUser(email string) error {
+	s.usersLock.Lock()
+	defer s.usersLock.Unlock()
+
+	if len(email) == 0 || !s.clients.Delete(email) {
+		return newError("user not found [%s]", email)
+	}
+	return nil
+}
+
+// Close stops the periodic tasks of the users and the sessions, the inbound calls it once closed.
 func (s *server) Close() error {
 	_ = s.clients.Close()
 	_ = s.sessionHistory.Close()
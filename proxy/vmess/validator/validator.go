@@ -186,6 +186,19 @@ func (v *TimedUserValidator) GetAEAD(userHash []byte) (vmess.User, bool, error)
 	return userd.(vmess.User), true, err
 }
 
+// Has returns true if a user of the email is added.
+func (v *TimedUserValidator) Has(email string) bool {
+	v.RLock()
+	defer v.RUnlock()
+
+	for _, u := range v.users {
+		if strings.EqualFold(u.user.Email, email) {
+			return true
+		}
+	}
+	return false
+}
+
 func (v *TimedUserValidator) Delete(email string) bool {
 	v.Lock()
 	defer v.Unlock()
@@ -193,7 +206,7 @@ func (v *TimedUserValidator) Delete(email string) bool {
 	email = strings.ToLower(email)
 	idx := -1
 	for i, u := range v.users {
-		if strings.EqualFold(u.user.Email(), email) {
+		if strings.EqualFold(u.user.Email, email) {
 			idx = i
 			var cmdkeyfl [16]byte
 			copy(cmdkeyfl[:], u.user.ID.CmdKey())
@@ -0,0 +1,32 @@
+package internet
+
+import (
+	"syscall"
+	"time"
+)
+
+// Sockopt is the options of the tcp sockets dialed or listened.
+type Sockopt struct {
+	// Mark is the SO_MARK of the socket on linux, zero is unset.
+	Mark int
+	// Interface binds the socket to the device on linux, like "eth0".
+	Interface string
+	// KeepAlive is the tcp keep alive period, zero is the default of the system and negative disables it.
+	KeepAlive time.Duration
+}
+
+func (s Sockopt) control() DialerControlFunc {
+	if s.Mark == 0 && len(s.Interface) == 0 {
+		return nil
+	}
+
+	return func(_, _ string, c syscall.RawConn) error {
+		var err error
+		if err2 := c.Control(func(fd uintptr) {
+			err = applySockopt(fd, s)
+		}); err2 != nil {
+			return err2
+		}
+		return err
+	}
+}
@@ -0,0 +1,23 @@
+//go:build linux
+
+package internet
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+func applySockopt(fd uintptr, s Sockopt) error {
+	if s.Mark != 0 {
+		if err := unix.SetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_MARK, s.Mark); err != nil {
+			return newError("failed to set mark %d", s.Mark).WithError(err)
+		}
+	}
+
+	if len(s.Interface) > 0 {
+		if err := unix.BindToDevice(int(fd), s.Interface); err != nil {
+			return newError("failed to bind interface %s", s.Interface).WithError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,7 @@
+//go:build !linux
+
+package internet
+
+func applySockopt(_ uintptr, _ Sockopt) error {
+	return newError("mark and interface are only supported on linux")
+}
@@ -11,6 +11,17 @@ func DialUDPSystem(src net.Address) (net.PacketConn, error) {
 }
 
 func DialTCPSystem(src, dst net.Address) (net.Conn, error) {
+	return DialTCPSystemSockopt(Sockopt{})(src, dst)
+}
+
+// DialTCPSystemSockopt dials the tcp connections with the options of the sockets.
+func DialTCPSystemSockopt(sockopt Sockopt) DialTCPFunc {
+	return func(src, dst net.Address) (net.Conn, error) {
+		return dialTCPSystem(src, dst, sockopt)
+	}
+}
+
+func dialTCPSystem(src, dst net.Address, sockopt Sockopt) (net.Conn, error) {
 	dialSystem := func(dst net.Address, dialer net.Dialer) (net.Conn, error) {
 		return dialer.Dial(dst.Network.This(), dst.DomainPreferredAddress())
 	}
@@ -21,6 +32,8 @@ func DialTCPSystem(src, dst net.Address) (net.Conn, error) {
 
 		dialer := &net.GoDialer{
 			LocalAddr: src2.AddrWithIPAddress(),
+			KeepAlive: sockopt.KeepAlive,
+			Control:   sockopt.control(),
 		}
 
 		return net.NewDialer(net.LocalDialFunc(src, dialer), net.LocalLookupIPFunc)
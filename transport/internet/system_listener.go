@@ -1,10 +1,12 @@
 package internet
 
 import (
+	"context"
 	"strings"
 	"time"
 
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/signal"
 )
 
 // ListenPacketSystem listens on a local address for incoming UDP connections.
@@ -15,7 +17,8 @@ func ListenPacketSystem(address net.Address) (net.PacketConn, error) {
 type systemListener struct {
 	net.Listener
 
-	ch chan net.Conn
+	ch   chan net.Conn
+	done signal.Done
 }
 
 func (l *systemListener) Receive() <-chan net.Conn {
@@ -26,9 +29,17 @@ func (l *systemListener) Accept() (net.Conn, error) {
 	select {
 	case conn := <-l.ch:
 		return conn, nil
+	case <-l.done.Wait():
+		return nil, newError("listener closed")
 	}
 }
 
+func (l *systemListener) Close() error {
+	_ = l.done.Close()
+
+	return l.Listener.Close()
+}
+
 func (l *systemListener) keepAccepting() {
 	for {
 		conn, err := l.Listener.Accept()
@@ -45,13 +56,32 @@ func (l *systemListener) keepAccepting() {
 
 		select {
 		case l.ch <- conn:
+		case <-l.done.Wait():
+			_ = conn.Close()
+			return
 		}
 	}
 }
 
 // ListenSystem listens on a local address for incoming TCP connections.
 func ListenSystem(address net.Address) (Listener, error) {
-	listener, err := net.Listen(address.Network.This(), address.IPAddress())
+	return ListenSystemSockopt(Sockopt{})(address)
+}
+
+// ListenSystemSockopt listens for incoming TCP connections with the options of the sockets.
+func ListenSystemSockopt(sockopt Sockopt) ListenerFunc {
+	return func(address net.Address) (Listener, error) {
+		return listenSystem(address, sockopt)
+	}
+}
+
+func listenSystem(address net.Address, sockopt Sockopt) (Listener, error) {
+	config := &net.ListenConfig{
+		KeepAlive: sockopt.KeepAlive,
+		Control:   sockopt.control(),
+	}
+
+	listener, err := config.Listen(context.Background(), address.Network.This(), address.IPAddress())
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +89,7 @@ func ListenSystem(address net.Address) (Listener, error) {
 	l := &systemListener{
 		Listener: listener,
 		ch:       make(chan net.Conn),
+		done:     signal.NewDone(),
 	}
 
 	go l.keepAccepting()
@@ -9,3 +9,8 @@ import (
 func Dial(src, dst net.Address) (net.Conn, error) {
 	return internet.DialTCPSystem(src, dst)
 }
+
+// DialSockopt dials the tcp connections with the options of the sockets.
+func DialSockopt(sockopt internet.Sockopt) internet.DialTCPFunc {
+	return internet.DialTCPSystemSockopt(sockopt)
+}
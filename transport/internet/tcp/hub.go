@@ -8,3 +8,8 @@ import (
 func Listen(address net.Address) (internet.Listener, error) {
 	return internet.ListenSystem(address)
 }
+
+// ListenSockopt listens with the options of the sockets.
+func ListenSockopt(sockopt internet.Sockopt) internet.ListenerFunc {
+	return internet.ListenSystemSockopt(sockopt)
+}
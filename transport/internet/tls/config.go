@@ -19,13 +19,22 @@ type Config struct {
 	ServerName string
 	// Certificate to be served on server.
 	Certificate Certificate
+	// AllowInsecure skips verifying the certificate of the server.
+	AllowInsecure bool
+	// NextProtos overrides the ALPN, the ClientOption is used on client if empty.
+	NextProtos []string
 }
 
 func (c Config) BuildTLSClient() *tls.Config {
 	return &tls.Config{
 		ServerName:         c.ServerName,
-		InsecureSkipVerify: ClientOption.AllowInsecure,
-		NextProtos:         ClientOption.NextProtos,
+		InsecureSkipVerify: ClientOption.AllowInsecure || c.AllowInsecure,
+		NextProtos: func() []string {
+			if len(c.NextProtos) > 0 {
+				return c.NextProtos
+			}
+			return ClientOption.NextProtos
+		}(),
 	}
 }
 
@@ -37,6 +46,7 @@ func (c Config) BuildTLSServer() (*tls.Config, error) {
 
 	return &tls.Config{
 		Certificates: []tls.Certificate{certificate},
+		NextProtos:   c.NextProtos,
 	}, nil
 }
 
@@ -2,13 +2,15 @@ package tls
 
 import (
 	"v2ray.com/core/common/net"
+	"v2ray.com/core/common/signal"
 	"v2ray.com/core/transport/internet"
 )
 
 type tlsListener struct {
 	internet.Listener
 
-	ch chan net.Conn
+	ch   chan net.Conn
+	done signal.Done
 
 	config Config
 }
@@ -17,8 +19,18 @@ func (l *tlsListener) Receive() <-chan net.Conn {
 	return l.ch
 }
 
+// Accept makes the listener a net.Listener, so that a websocket is served over tls.
+func (l *tlsListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.ch:
+		return c, nil
+	case <-l.done.Wait():
+		return nil, newError("listener closed")
+	}
+}
+
 func (l *tlsListener) Close() error {
-	close(l.ch)
+	_ = l.done.Close()
 
 	return l.Listener.Close()
 }
@@ -26,16 +38,26 @@ func (l *tlsListener) Close() error {
 func (l *tlsListener) keepAccepting() {
 	for {
 		select {
-		case c := <-l.Listener.Receive():
+		case c, ok := <-l.Listener.Receive():
+			if !ok {
+				return
+			}
+
 			c2, err := Server(c, l.config)
 			if err != nil {
 				newError("failed to accept raw connections").WithError(err).AtDebug().Logging()
+				_ = c.Close()
 				continue
 			}
 
 			select {
 			case l.ch <- c2:
+			case <-l.done.Wait():
+				_ = c2.Close()
+				return
 			}
+		case <-l.done.Wait():
+			return
 		}
 	}
 }
@@ -53,6 +75,8 @@ func Listen(setting ListenSetting, listenerFunc internet.ListenerFunc) internet.
 
 		l := &tlsListener{
 			Listener: l0,
+			ch:       make(chan net.Conn),
+			done:     signal.NewDone(),
 			config:   setting.Config,
 		}
 
@@ -70,7 +70,7 @@ func (d *symmetricDispatcher) doHandleInput(setting DispatchSetting) (transport.
 
 		go func() {
 			if err := d.handleInput(setting, link); err != nil {
-				newError("failed to handle UDP input").WithError(err).AtDebug().Logging()
+				newError("failed to handle UDP input").WithError(err).WithSession(setting.Content).AtDebug().Logging()
 			}
 		}()
 
@@ -1,6 +1,7 @@
 package udp
 
 import (
+	"sync"
 	"time"
 
 	"v2ray.com/core/common/buffer"
@@ -26,6 +27,7 @@ type hub struct {
 	conn    net.PacketConn
 	ch      chan net.Conn
 	pool    cache.Pool
+	done    signal.Done
 }
 
 func (h *hub) Receive() <-chan net.Conn {
@@ -35,7 +37,7 @@ func (h *hub) Receive() <-chan net.Conn {
 func (h *hub) Close() error {
 	_ = h.pool.Close()
 
-	close(h.ch)
+	_ = h.done.Close()
 
 	return h.conn.Close()
 }
@@ -92,6 +94,9 @@ func (h *hub) handle() {
 
 			select {
 			case h.ch <- conn:
+			case <-h.done.Wait():
+				pkt.Payload.Release()
+				return newError("hub closed")
 			}
 		}
 
@@ -101,6 +106,9 @@ func (h *hub) handle() {
 	for {
 		pkt, err := receive()
 		if err != nil {
+			if h.done.Done() {
+				return
+			}
 			newError("failed to read UDP conn").WithError(err).AtDebug().Logging()
 			continue
 		}
@@ -122,6 +130,7 @@ func Listen(address net.Address) (internet.Hub, error) {
 		conn:    conn,
 		ch:      make(chan net.Conn),
 		pool:    cache.NewPool(),
+		done:    signal.NewDone(),
 	}
 
 	go h.handle()
@@ -144,6 +153,7 @@ type udpConn struct {
 	closer      io.CloseFunc
 	pending     udp_proto.PipeReadWriteCloser
 	closeSignal signal.Notifier
+	closeOnce   sync.Once
 }
 
 func newUDPConn(writeFunc io.WriteFunc, closeFunc io.CloseFunc, lis, src, _ net.Address) *udpConn {
@@ -191,7 +201,11 @@ func (c *udpConn) keepCloser() error {
 		select {
 		case <-timer.C:
 			return c.pending.Close()
-		case <-c.closeSignal.Wait():
+		case _, ok := <-c.closeSignal.Wait():
+			// the signal is closed with the conn
+			if !ok {
+				return nil
+			}
 		}
 	}
 }
@@ -206,12 +220,17 @@ func (c *udpConn) ReadFrom(p []byte) (int, net.Addr, error) {
 	return n, nil, err
 }
 
+// Close could be called more than once, like by the policy of the inbound and the inbound itself.
 func (c *udpConn) Close() error {
-	_ = c.closeSignal.Close()
+	var err error
+	c.closeOnce.Do(func() {
+		_ = c.closeSignal.Close()
 
-	// _ = c.pending.Close()
+		// _ = c.pending.Close()
 
-	return c.closer()
+		err = c.closer()
+	})
+	return err
 }
 
 func (c *udpConn) LocalAddr() net.Addr {
@@ -18,15 +18,18 @@ const (
 )
 
 type DialSetting struct {
-	Path      string
-	TLSConfig tls.Config
+	Path string
+	// Headers are sent in the upgrade request, a "Host" overrides the server name of the tls.
+	Headers map[string]string
+	// TLSConfig dials wss if it is not nil.
+	TLSConfig *tls.Config
 }
 
 func Dial(setting DialSetting, dialTCPFunc internet.DialTCPFunc) internet.DialTCPFunc {
 	return func(src, dst net.Address) (net.Conn, error) {
 		uri := func() string {
 			name := func() string {
-				if len(setting.TLSConfig.ServerName) > 0 {
+				if setting.TLSConfig != nil {
 					return protocolNameTLS
 				}
 				return protocolName
@@ -45,7 +48,7 @@ func Dial(setting DialSetting, dialTCPFunc internet.DialTCPFunc) internet.DialTC
 				return dialTCPFunc(src, dst)
 			},
 			TLSClientConfig: func() *gotls.Config {
-				if len(setting.TLSConfig.ServerName) > 0 {
+				if setting.TLSConfig != nil {
 					return setting.TLSConfig.BuildTLSClient()
 				}
 				return nil
@@ -55,9 +58,15 @@ func Dial(setting DialSetting, dialTCPFunc internet.DialTCPFunc) internet.DialTC
 			HandshakeTimeout: 8 * time.Second,
 		}
 
-		conn, _, err := dialer.Dial(uri, http.Header{
-			"Host": []string{setting.TLSConfig.ServerName},
-		})
+		header := http.Header{}
+		if setting.TLSConfig != nil && len(setting.TLSConfig.ServerName) > 0 {
+			header.Set("Host", setting.TLSConfig.ServerName)
+		}
+		for k, v := range setting.Headers {
+			header[http.CanonicalHeaderKey(k)] = []string{v}
+		}
+
+		conn, _, err := dialer.Dial(uri, header)
 		if err != nil {
 			return nil, err
 		}
@@ -6,6 +6,7 @@ import (
 
 	"v2ray.com/core/common/net"
 	http_proto "v2ray.com/core/common/protocol/http"
+	"v2ray.com/core/common/signal"
 	"v2ray.com/core/transport/internet"
 
 	"github.com/gorilla/websocket"
@@ -25,13 +26,14 @@ func (l *httpServer) Addr() net.Addr {
 }
 
 func (l *httpServer) Close() error {
-	close(l.handler.ch)
+	_ = l.handler.done.Close()
 
 	return l.server.Close()
 }
 
 type httpHandler struct {
-	ch chan net.Conn
+	ch   chan net.Conn
+	done signal.Done
 
 	path     string
 	upgrader *websocket.Upgrader
@@ -49,15 +51,18 @@ func (h *httpHandler) ServeHTTP(responseWriter http.ResponseWriter, request *htt
 			return newError("failed to convert websocket httpConn").WithError(err)
 		}
 
-		forwardedAddr, err := net.ParseHost(http_proto.ParseXForwardedFor(request.Header)[0])
-		if err != nil {
-			return err
-		}
-
+		// the X-Forwarded-For is only set behind a proxy
 		remoteAddr := conn.RemoteAddr()
-		if forwardedAddr.IsIPHost() {
-			remoteAddr = &net.TCPAddr{
-				IP: forwardedAddr.IP,
+		if forwarded := http_proto.ParseXForwardedFor(request.Header); len(forwarded) > 0 {
+			forwardedAddr, err := net.ParseHost(forwarded[0])
+			if err != nil {
+				return err
+			}
+
+			if forwardedAddr.IsIPHost() {
+				remoteAddr = &net.TCPAddr{
+					IP: forwardedAddr.IP,
+				}
 			}
 		}
 
@@ -66,6 +71,9 @@ func (h *httpHandler) ServeHTTP(responseWriter http.ResponseWriter, request *htt
 			conn:       conn,
 			remoteAddr: remoteAddr,
 		}:
+		case <-h.done.Wait():
+			_ = conn.Close()
+			return newError("server closed")
 		}
 
 		return nil
@@ -89,6 +97,7 @@ func Listen(setting ListenSetting, listenerFunc internet.ListenerFunc) internet.
 
 		handler := &httpHandler{
 			ch:   make(chan net.Conn),
+			done: signal.NewDone(),
 			path: setting.Path,
 			upgrader: &websocket.Upgrader{
 				ReadBufferSize:   4 * 1024,
@@ -204,10 +204,15 @@ func (p *pipe) readMultiBuffer() (buffer.MultiBuffer, error) {
 	return data, nil
 }
 
+// Close could be called more than once, like by killing the session and by its inbound.
 func (p *pipe) Close() error {
 	p.Lock()
 	defer p.Unlock()
 
+	if p.done.Done() {
+		return nil
+	}
+
 	// p.data.Release()
 
 	_ = p.readSignal.Close()